@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessLog writes one line per request in the Common or Combined Log Format
+// read by most log analysers.
+type accessLog struct {
+	mu       sync.Mutex
+	out      io.Writer
+	combined bool
+}
+
+func newAccessLog(out io.Writer, format string) (*accessLog, error) {
+	switch format {
+	case "common":
+		return &accessLog{out: out}, nil
+	case "combined":
+		return &accessLog{out: out, combined: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+}
+
+func (l *accessLog) log(r *http.Request, remoteIP string, status, bytes int, t time.Time) {
+	size := "-"
+	if bytes > 0 {
+		size = strconv.Itoa(bytes)
+	}
+
+	line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		remoteIP, t.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, escapeLogField(r.URL.RequestURI()), r.Proto, status, size)
+
+	if l.combined {
+		line += fmt.Sprintf(` "%s" "%s"`, logHeader(r, "Referer"), logHeader(r, "User-Agent"))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fmt.Fprintln(l.out, line)
+}
+
+func logHeader(r *http.Request, name string) string {
+	v := r.Header.Get(name)
+	if v == "" {
+		return "-"
+	}
+	return escapeLogField(v)
+}
+
+// escapeLogField keeps client supplied values from breaking out of their
+// quotes or forging extra lines.
+func escapeLogField(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// rotatingFile appends to the file at path. Once the file would grow past
+// maxSize it is renamed to path.1, older files moving up to path.N, and at
+// most backups of them are kept.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize. A
+// failed rotation is returned but p is still written to the current file, so
+// the log keeps going until the next rotation succeeds.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var rotateErr error
+
+	if f.file != nil && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		rotateErr = f.rotate()
+	}
+
+	if f.file == nil {
+		err := f.open()
+		if err != nil {
+			return 0, errors.Join(rotateErr, err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// rotate moves the file to path.1, older files up and the oldest out, then
+// opens path again. The file is reopened whatever fails on the way; if even
+// that fails f.file is left nil and Write tries again.
+func (f *rotatingFile) rotate() error {
+	errs := []error{f.file.Close()}
+	f.file = nil
+
+	if f.backups > 0 {
+		errs = append(errs, ignoreNotExist(os.Remove(fmt.Sprintf("%s.%d", f.path, f.backups))))
+
+		for i := f.backups - 1; i > 0; i-- {
+			errs = append(errs, ignoreNotExist(os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))))
+		}
+
+		errs = append(errs, os.Rename(f.path, f.path+".1"))
+	} else {
+		errs = append(errs, os.Remove(f.path))
+	}
+
+	errs = append(errs, f.open())
+	return errors.Join(errs...)
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("", 7*60*60))
+
+	r := httptest.NewRequest(http.MethodGet, "/snippets/search?q=pond", nil)
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", `curl/8.0 "quoted"`)
+
+	tests := []struct {
+		name string
+		format string
+		bytes int
+		want string
+	} {
+		{
+			name: "Common",
+			format: "common",
+			bytes: 512,
+			want: `192.0.2.1 - - [05/Mar/2024:14:07:09 +0700] "GET /snippets/search?q=pond HTTP/1.1" 200 512` + "\n",
+		},
+		{
+			name: "Combined",
+			format: "combined",
+			bytes: 0,
+			want: `192.0.2.1 - - [05/Mar/2024:14:07:09 +0700] "GET /snippets/search?q=pond HTTP/1.1" 200 - "http://example.com/" "curl/8.0 \"quoted\""` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+
+			l, err := newAccessLog(buf, test.format)
+			assert.NilError(t, err)
+
+			l.log(r, "192.0.2.1", http.StatusOK, test.bytes, at)
+			assert.Equal(t, buf.String(), test.want)
+		})
+	}
+
+	_, err := newAccessLog(new(bytes.Buffer), "apache")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	f, err := openRotatingFile(path, 10, 2)
+	assert.NilError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		_, err := f.Write([]byte(line))
+		assert.NilError(t, err)
+	}
+
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+
+	// The oldest file, holding one and two, has been dropped.
+	assert.Equal(t, read(path), "six\n")
+	assert.Equal(t, read(path+".1"), "four\nfive\n")
+	assert.Equal(t, read(path+".2"), "three\n")
+	assert.Equal(t, read(path+".3"), "")
+}
+
+func TestRotatingFileRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	// A directory in the way of the first backup stops rotation.
+	blocker := filepath.Join(path+".1", "keep")
+	assert.NilError(t, os.MkdirAll(blocker, 0o755))
+
+	f, err := openRotatingFile(path, 10, 1)
+	assert.NilError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("one\n"))
+	assert.NilError(t, err)
+
+	for _, line := range []string{"two two\n", "three\n"} {
+		n, err := f.Write([]byte(line))
+		assert.Equal(t, err != nil, true)
+		assert.Equal(t, n, len(line))
+	}
+
+	// Nothing was lost and the next rotation succeeds.
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "one\ntwo two\nthree\n")
+
+	assert.NilError(t, os.RemoveAll(path+".1"))
+
+	_, err = f.Write([]byte("four\n"))
+	assert.NilError(t, err)
+
+	b, err = os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "four\n")
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the smallest body worth compressing. Anything shorter
+// tends to grow once the encoding's framing is added.
+const minCompressSize = 512
+
+// encodings lists the content codings the server can produce, best first.
+var encodings = []string{"br", "gzip"}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression); return w }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }}
+)
+
+// compress encodes responses with the best coding the client accepts.
+// Responses that are already encoded, partial, empty or not text-like are
+// sent untouched.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the coding from an Accept-Encoding header with the
+// highest quality, preferring the order of encodings on ties. It returns ""
+// when the response should be sent as is.
+func negotiateEncoding(header string) string {
+	quality := map[string]float64{}
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		quality[name] = q
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, name := range encodings {
+		q, ok := quality[name]
+		if !ok {
+			q, ok = quality["*"]
+		}
+
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// compressible reports whether a body of type contentType gains from
+// compression. Images, archives and fonts are already compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/problem+json", "application/javascript",
+		"application/xml", "image/svg+xml", "image/x-icon", "image/vnd.microsoft.icon":
+		return true
+	}
+
+	return false
+}
+
+// compressWriter holds back the status line until the first write, so the
+// decision to compress can take the body's type and size into account.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	sent     bool
+	enc      io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.sent {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.sendHeader(b)
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) sendHeader(first []byte) {
+	cw.sent = true
+	h := cw.Header()
+
+	if h.Get("Content-Type") == "" && len(first) > 0 {
+		h.Set("Content-Type", http.DetectContentType(first))
+	}
+
+	size := len(first)
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil {
+		size = n
+	}
+
+	switch {
+	case cw.status == http.StatusNoContent, cw.status == http.StatusPartialContent, cw.status == http.StatusNotModified:
+	case h.Get("Content-Encoding") != "", size < minCompressSize, !compressible(h.Get("Content-Type")):
+	default:
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+
+		// The encoded body is a different representation, so a strong
+		// validator of the plain body no longer matches it byte for byte.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		cw.enc = newEncoder(cw.encoding, cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.sent && cw.status != 0 {
+		cw.sendHeader(nil)
+	}
+
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close sends a status that was set without a body and finishes the encoded
+// stream.
+func (cw *compressWriter) close() {
+	if !cw.sent && cw.status != 0 {
+		cw.sendHeader(nil)
+	}
+
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// pooledWriter returns its encoder to the pool once the stream is finished.
+type pooledWriter struct {
+	io.WriteCloser
+	pool *sync.Pool
+}
+
+func (p *pooledWriter) Flush() error {
+	return p.WriteCloser.(interface{ Flush() error }).Flush()
+}
+
+func (p *pooledWriter) Close() error {
+	err := p.WriteCloser.Close()
+	p.pool.Put(p.WriteCloser)
+	return err
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case "br":
+		bw := brotliWriters.Get().(*brotli.Writer)
+		bw.Reset(w)
+		return &pooledWriter{bw, &brotliWriters}
+	default:
+		gw := gzipWriters.Get().(*gzip.Writer)
+		gw.Reset(w)
+		return &pooledWriter{gw, &gzipWriters}
+	}
+}
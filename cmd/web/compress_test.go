@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/ui"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"identity", ""},
+		{"GZIP", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, negotiateEncoding(tt.header), tt.want)
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	page := strings.Repeat("<p>An old silent pond</p>\n", 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{
+			name:           "Brotli",
+			acceptEncoding: "gzip, br",
+			body:           page,
+			wantEncoding:   "br",
+		},
+		{
+			name:           "Gzip",
+			acceptEncoding: "gzip",
+			body:           page,
+			wantEncoding:   "gzip",
+		},
+		{
+			name: "Not Accepted",
+			body: page,
+		},
+		{
+			name:           "Small Body",
+			acceptEncoding: "gzip",
+			body:           "<p>ok</p>",
+		},
+		{
+			name:           "Already Compressed Type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           page,
+		},
+		{
+			name:           "Error Page",
+			acceptEncoding: "gzip",
+			status:         http.StatusInternalServerError,
+			body:           page,
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "Not Modified",
+			acceptEncoding: "gzip",
+			status:         http.StatusNotModified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				io.WriteString(w, tt.body)
+			})
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			compress(next).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			defer rs.Body.Close()
+
+			wantStatus := tt.status
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+
+			assert.Equal(t, rs.StatusCode, wantStatus)
+			assert.Equal(t, rs.Header.Get("Content-Encoding"), tt.wantEncoding)
+			assert.Equal(t, rs.Header.Get("Vary"), "Accept-Encoding")
+
+			var body io.Reader = rs.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(rs.Body)
+				assert.NilError(t, err)
+				body = zr
+				assert.Equal(t, rs.Header.Get("ETag"), `W/"v1"`)
+			case "br":
+				body = brotli.NewReader(rs.Body)
+				assert.Equal(t, rs.Header.Get("ETag"), `W/"v1"`)
+			default:
+				assert.Equal(t, rs.Header.Get("ETag"), `"v1"`)
+			}
+
+			got, err := io.ReadAll(body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tt.body)
+
+			if tt.wantEncoding != "" {
+				assert.Equal(t, rr.Body.Len() < len(tt.body), true)
+			}
+		})
+	}
+}
+
+func TestCompressStaticFiles(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/static/css/main.css", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rs, err := ts.Client().Do(req)
+	assert.NilError(t, err)
+	defer rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Encoding"), "gzip")
+
+	zr, err := gzip.NewReader(rs.Body)
+	assert.NilError(t, err)
+
+	got, err := io.ReadAll(zr)
+	assert.NilError(t, err)
+
+	want, err := fs.ReadFile(ui.Files, "static/css/main.css")
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(got, want), true)
+}
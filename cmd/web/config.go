@@ -0,0 +1,459 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config holds every setting of the server. Each field is bound to a flag,
+// and loadFlags lets the same setting come from a SNIPPETBOX_* environment
+// variable or a config file instead.
+type config struct {
+	Addr         string
+	AdminAddr    string
+	Driver       string
+	DSN          string
+	CursorSecret string
+	Dev          bool
+	UIDir        string
+	AutoMigrate  bool
+
+	Security securityPolicy
+
+	TLSCert         string
+	TLSKey          string
+	TLSSelfSigned   bool
+	TLSRedirectAddr string
+
+	Limits         rateLimitConfig
+	LimitStore     string
+	TrustedProxies string
+
+	LogFormat string
+	LogLevel  slog.Level
+
+	AccessLogPath    string
+	AccessLogFormat  string
+	AccessLogMaxSize int64
+	AccessLogBackups int
+
+	Tracing tracingConfig
+
+	Timeouts serverTimeouts
+	Shutdown shutdownConfig
+	DB       dbPoolConfig
+
+	PrintConfig bool
+
+	flags *flag.FlagSet
+}
+
+// envPrefix starts the environment variable of every flag, so -limit-read-rps
+// can be set with SNIPPETBOX_LIMIT_READ_RPS.
+const envPrefix = "SNIPPETBOX_"
+
+// secretFlags hold passwords and keys. Each can also be read from the file
+// named by the flag of the same name ending in -file, and is redacted by
+// -print-config.
+var secretFlags = []string{"dsn", "cursor-secret"}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig reads the server configuration from args, the environment and
+// the config file, and checks it.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*config, error) {
+	cfg := &config{flags: flag.NewFlagSet("snippetbox", flag.ContinueOnError)}
+	fs := cfg.flags
+
+	fs.StringVar(&cfg.Addr, "addr", ":4000", "HTTP Network Address")
+	fs.StringVar(&cfg.AdminAddr, "admin-addr", "localhost:4001", "Network address of the admin listener serving /metrics, /healthz, /readyz and /version (disabled if empty)")
+	fs.StringVar(&cfg.Driver, "driver", "mysql", "Database driver (mysql or postgres)")
+	fs.StringVar(&cfg.DSN, "dsn", "web:password@/snippetbox?parseTime=true", "Data Source Name")
+	fs.StringVar(&cfg.CursorSecret, "cursor-secret", "", "Key used to sign paging cursors, the same on every instance so links keep working across restarts (required unless -dev, which makes up a key at each start)")
+	fs.BoolVar(&cfg.Dev, "dev", false, "Development mode: reload templates as they change, show template errors in the browser and turn off caching")
+	fs.StringVar(&cfg.UIDir, "ui-dir", "", "Read templates and static files from this directory instead of the copies built into the binary")
+	fs.BoolVar(&cfg.AutoMigrate, "auto-migrate", false, "Apply pending migrations at startup")
+	fs.DurationVar(&cfg.Security.HSTS.MaxAge, "hsts-max-age", defaultSecurity.HSTS.MaxAge, "How long browsers should only use HTTPS, sent over HTTPS (0 disables Strict-Transport-Security; never sent with -dev)")
+	fs.BoolVar(&cfg.Security.HSTS.IncludeSubdomains, "hsts-include-subdomains", defaultSecurity.HSTS.IncludeSubdomains, "Extend Strict-Transport-Security to every subdomain")
+	fs.BoolVar(&cfg.Security.HSTS.Preload, "hsts-preload", defaultSecurity.HSTS.Preload, "Ask to be included in the browsers' HSTS preload lists")
+	fs.StringVar(&cfg.Security.FrameAncestors, "frame-ancestors", defaultSecurity.FrameAncestors, "Sources allowed to frame pages (Content-Security-Policy frame-ancestors)")
+	fs.StringVar(&cfg.Security.EmbedRoutes, "embed-routes", defaultSecurity.EmbedRoutes, "Comma separated route patterns, such as /snippet/view/:id, that embed-ancestors may frame. Embedding is opt-in: empty by default, so every page refuses framing, and there is no dedicated embed endpoint")
+	fs.StringVar(&cfg.Security.EmbedAncestors, "embed-ancestors", defaultSecurity.EmbedAncestors, "Sources allowed to frame the embed-routes")
+	fs.StringVar(&cfg.Security.ReferrerPolicy, "referrer-policy", defaultSecurity.ReferrerPolicy, "Referrer-Policy header (none if empty)")
+	fs.StringVar(&cfg.Security.PermissionsPolicy, "permissions-policy", defaultSecurity.PermissionsPolicy, "Permissions-Policy header (none if empty)")
+	fs.StringVar(&cfg.Security.OpenerPolicy, "cross-origin-opener-policy", defaultSecurity.OpenerPolicy, "Cross-Origin-Opener-Policy header (none if empty)")
+	fs.StringVar(&cfg.Security.ResourcePolicy, "cross-origin-resource-policy", defaultSecurity.ResourcePolicy, "Cross-Origin-Resource-Policy header (none if empty)")
+	fs.BoolVar(&cfg.Security.CSPReportOnly, "csp-report-only", defaultSecurity.CSPReportOnly, "Only report Content-Security-Policy violations to /csp-report instead of blocking them")
+
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "TLS certificate file (serves HTTPS when set)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "TLS private key file")
+	fs.BoolVar(&cfg.TLSSelfSigned, "tls-self-signed", false, "Generate a self-signed certificate for localhost when the certificate files don't exist (development only)")
+	fs.StringVar(&cfg.TLSRedirectAddr, "tls-redirect-addr", "", "Network address of a plain HTTP listener redirecting to HTTPS (disabled if empty)")
+
+	fs.Float64Var(&cfg.Limits.Read.Rate, "limit-read-rps", 10, "Page views allowed per second for each client")
+	fs.IntVar(&cfg.Limits.Read.Burst, "limit-read-burst", 20, "Page views a client can make in a burst (0 disables the limit)")
+	fs.Float64Var(&cfg.Limits.Write.Rate, "limit-write-rps", 0.5, "Form submissions allowed per second for each client")
+	fs.IntVar(&cfg.Limits.Write.Burst, "limit-write-burst", 10, "Form submissions a client can make in a burst (0 disables the limit)")
+	fs.Float64Var(&cfg.Limits.Search.Rate, "limit-search-rps", 2, "Searches allowed per second for each client")
+	fs.IntVar(&cfg.Limits.Search.Burst, "limit-search-burst", 10, "Searches a client can make in a burst (0 disables the limit)")
+	fs.DurationVar(&cfg.Limits.Idle, "limit-idle", 10*time.Minute, "How long to remember a client after its last request")
+	fs.StringVar(&cfg.LimitStore, "limit-store", "memory", "Where rate limits are kept: memory (per instance) or database (shared by every instance)")
+	fs.StringVar(&cfg.TrustedProxies, "trusted-proxies", "", "Comma separated addresses or CIDRs of proxies whose X-Forwarded-For header is trusted")
+
+	fs.StringVar(&cfg.LogFormat, "log-format", "text", "Log output format (text or json)")
+	fs.TextVar(&cfg.LogLevel, "log-level", slog.LevelInfo, "Minimum level logged (debug, info, warn or error)")
+
+	fs.StringVar(&cfg.AccessLogPath, "access-log", "", "Access log file, or - for stdout (none if empty)")
+	fs.StringVar(&cfg.AccessLogFormat, "access-log-format", "combined", "Access log format (common or combined)")
+	fs.Int64Var(&cfg.AccessLogMaxSize, "access-log-max-size", 100, "Size in MB at which the access log file is rotated")
+	fs.IntVar(&cfg.AccessLogBackups, "access-log-backups", 5, "Number of rotated access log files to keep")
+
+	fs.StringVar(&cfg.Tracing.Exporter, "trace-exporter", "none", "Where to send traces: none, otlp, stdout or file")
+	fs.StringVar(&cfg.Tracing.Endpoint, "trace-endpoint", "", "OTLP/HTTP collector URL (defaults to the OTEL_EXPORTER_OTLP_* environment variables)")
+	fs.StringVar(&cfg.Tracing.File, "trace-file", "traces.json", "File the file trace exporter appends to")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "trace-sample-ratio", 1, "Share of new traces to record, from 0 to 1")
+
+	fs.DurationVar(&cfg.Timeouts.ReadHeader, "read-header-timeout", 5*time.Second, "Time allowed to read a request's headers")
+	fs.DurationVar(&cfg.Timeouts.Read, "read-timeout", 30*time.Second, "Time allowed to read a whole request, including the body")
+	fs.DurationVar(&cfg.Timeouts.Write, "write-timeout", time.Minute, "Time allowed from the end of the request headers to the end of the response")
+	fs.DurationVar(&cfg.Timeouts.Idle, "idle-timeout", time.Minute, "How long a keep-alive connection may wait for its next request")
+	fs.DurationVar(&cfg.Shutdown.Delay, "shutdown-delay", 0, "How long to keep serving, with /readyz failing, after a shutdown signal")
+	fs.DurationVar(&cfg.Shutdown.Timeout, "shutdown-timeout", 30*time.Second, "How long in-flight requests may take to finish during shutdown")
+
+	fs.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", 25, "Most database connections open at once (0 for no limit)")
+	fs.IntVar(&cfg.DB.MaxIdleConns, "db-max-idle-conns", 25, "Most idle database connections kept (0 for the default of 2)")
+	fs.DurationVar(&cfg.DB.ConnMaxLifetime, "db-conn-max-lifetime", 30*time.Minute, "How long a database connection may be reused (0 for ever)")
+	fs.DurationVar(&cfg.DB.ConnMaxIdleTime, "db-conn-max-idle-time", 5*time.Minute, "How long a database connection may sit idle before it's closed (0 for ever)")
+
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the configuration, with secrets redacted, and exit")
+
+	err := loadFlags(fs, args, lookupEnv, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Dev && cfg.UIDir == "" {
+		cfg.UIDir = "./ui"
+	}
+
+	// A browser remembers HSTS for the host, so a development certificate
+	// for localhost would stick to every other app served there.
+	if cfg.Dev {
+		cfg.Security.HSTS.MaxAge = 0
+	}
+
+	if cfg.TLSSelfSigned && cfg.TLSCert == "" && cfg.TLSKey == "" {
+		cfg.TLSCert, cfg.TLSKey = "./tls/cert.pem", "./tls/key.pem"
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// validate checks the settings that can be checked without opening files or
+// connections, so that a bad deployment fails before it starts anything.
+func (cfg *config) validate() error {
+	var errs []error
+
+	switch cfg.Driver {
+	case "mysql", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported database driver %q", cfg.Driver))
+	}
+
+	// A key made up at startup would break every paging link handed out
+	// before a restart, and on every other instance.
+	if cfg.CursorSecret == "" && !cfg.Dev {
+		errs = append(errs, errors.New("cursor-secret is required unless -dev is set"))
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+	}
+
+	if cfg.TLSRedirectAddr != "" && cfg.TLSCert == "" {
+		errs = append(errs, errors.New("tls-redirect-addr needs a TLS certificate"))
+	}
+
+	switch cfg.LimitStore {
+	case "memory", "database":
+	default:
+		errs = append(errs, fmt.Errorf("unknown rate limit store %q", cfg.LimitStore))
+	}
+
+	proxies, err := parseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("trusted-proxies: %w", err))
+	}
+	cfg.Limits.TrustedProxies = proxies
+
+	err = cfg.Limits.validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = cfg.Security.validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	switch cfg.LogFormat {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log format %q", cfg.LogFormat))
+	}
+
+	switch cfg.AccessLogFormat {
+	case "common", "combined":
+	default:
+		errs = append(errs, fmt.Errorf("unknown access log format %q", cfg.AccessLogFormat))
+	}
+
+	if cfg.AccessLogMaxSize <= 0 || cfg.AccessLogBackups < 0 {
+		errs = append(errs, errors.New("access-log-max-size must be positive and access-log-backups not negative"))
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "none", "otlp", "stdout", "file":
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace exporter %q", cfg.Tracing.Exporter))
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("trace-sample-ratio %v is not between 0 and 1", cfg.Tracing.SampleRatio))
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read-header-timeout", cfg.Timeouts.ReadHeader},
+		{"read-timeout", cfg.Timeouts.Read},
+		{"write-timeout", cfg.Timeouts.Write},
+		{"idle-timeout", cfg.Timeouts.Idle},
+		{"shutdown-delay", cfg.Shutdown.Delay},
+		{"db-conn-max-lifetime", cfg.DB.ConnMaxLifetime},
+		{"db-conn-max-idle-time", cfg.DB.ConnMaxIdleTime},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", d.name))
+		}
+	}
+
+	if cfg.Shutdown.Timeout <= 0 {
+		errs = append(errs, errors.New("shutdown-timeout must be positive"))
+	}
+
+	if cfg.DB.MaxOpenConns < 0 || cfg.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db-max-open-conns and db-max-idle-conns must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// print writes the configuration in the format of the config file. Secrets
+// are redacted so the output can be shared.
+func (cfg *config) print(w io.Writer) {
+	secret := map[string]bool{}
+	for _, name := range secretFlags {
+		secret[name] = true
+		secret[name+"-file"] = true
+	}
+
+	cfg.flags.VisitAll(func(f *flag.Flag) {
+		switch {
+		case f.Name == "config" || f.Name == "print-config":
+			return
+		case secret[f.Name] && f.Value.String() != "":
+			fmt.Fprintf(w, "%s = %q\n", f.Name, "REDACTED")
+			return
+		}
+
+		switch f.Value.(flag.Getter).Get().(type) {
+		case bool, int, int64, float64:
+			fmt.Fprintf(w, "%s = %s\n", f.Name, f.Value)
+		default:
+			fmt.Fprintf(w, "%s = %q\n", f.Name, f.Value)
+		}
+	})
+}
+
+// Where a flag's value came from, in increasing order of precedence.
+const (
+	fromDefault = iota
+	fromConfigFile
+	fromEnv
+	fromCommandLine
+)
+
+// loadFlags parses args into fs and fills every flag that wasn't on the
+// command line from its SNIPPETBOX_* environment variable or, failing that,
+// the TOML file named by -config. Secrets may instead be read from a file
+// named by their -file flag, e.g. -dsn-file, which counts as coming from
+// wherever that flag was set. Unless strict, settings in the file that fs
+// doesn't have are ignored, so commands with fewer flags can share the file.
+func loadFlags(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool), strict bool) error {
+	configFile := fs.String("config", "", "TOML configuration file, overridden by SNIPPETBOX_* environment variables and flags")
+
+	secretFiles := map[string]*string{}
+	for _, name := range secretFlags {
+		if fs.Lookup(name) != nil {
+			secretFiles[name] = fs.String(name+"-file", "", fmt.Sprintf("File holding the value of -%s, to keep it out of the command line", name))
+		}
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	source := map[string]int{}
+	fs.Visit(func(f *flag.Flag) { source[f.Name] = fromCommandLine })
+
+	if source["config"] == fromDefault {
+		if path, ok := lookupEnv(envName("config")); ok {
+			*configFile = path
+		}
+	}
+
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if fs.Lookup(name) == nil || name == "config" {
+				if strict {
+					return fmt.Errorf("%s: unknown setting %q", *configFile, name)
+				}
+				continue
+			}
+
+			if source[name] > fromConfigFile {
+				continue
+			}
+
+			err := fs.Set(name, values[name])
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", *configFile, name, err)
+			}
+			source[name] = fromConfigFile
+		}
+	}
+
+	var envErr error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := lookupEnv(envName(f.Name))
+		if !ok || envErr != nil || f.Name == "config" || source[f.Name] > fromEnv {
+			return
+		}
+
+		err := fs.Set(f.Name, value)
+		if err != nil {
+			envErr = fmt.Errorf("%s: %w", envName(f.Name), err)
+		}
+		source[f.Name] = fromEnv
+	})
+
+	if envErr != nil {
+		return envErr
+	}
+
+	for name, file := range secretFiles {
+		fileSource := source[name+"-file"]
+
+		switch {
+		case *file == "" || source[name] > fileSource:
+			continue
+		case source[name] == fileSource:
+			return fmt.Errorf("set only one of %s and %s-file", name, name)
+		}
+
+		b, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+
+		err = fs.Set(name, strings.TrimRight(string(b), "\r\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readConfigFile reads a TOML file of settings named after their flags.
+// Tables prefix the names of their keys, so tls-cert can also be written as
+// cert in a [tls] table.
+func readConfigFile(path string) (map[string]string, error) {
+	var doc map[string]any
+
+	_, err := toml.DecodeFile(path, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+
+	err = flattenConfig("", doc, values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func flattenConfig(prefix string, table map[string]any, values map[string]string) error {
+	for key, v := range table {
+		name := strings.ReplaceAll(key, "_", "-")
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+
+		switch v := v.(type) {
+		case map[string]any:
+			err := flattenConfig(name, v, values)
+			if err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case string:
+			values[name] = v
+		case bool, int64, float64:
+			values[name] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("unsupported value for %s", name)
+		}
+	}
+
+	return nil
+}
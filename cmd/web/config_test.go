@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+// env returns a lookup function serving vars instead of the environment.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+// withCursorSecret adds the cursor secret that every configuration needs
+// outside -dev to vars.
+func withCursorSecret(vars map[string]string) map[string]string {
+	with := map[string]string{"SNIPPETBOX_CURSOR_SECRET": "test-cursor-key"}
+	for k, v := range vars {
+		with[k] = v
+	}
+	return with
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(nil, env(withCursorSecret(nil)))
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.Addr, ":4000")
+	assert.Equal(t, cfg.Driver, "mysql")
+	assert.Equal(t, cfg.Limits.Read.Burst, 20)
+	assert.Equal(t, cfg.Limits.Idle, 10*time.Minute)
+	assert.Equal(t, cfg.LogLevel.String(), "INFO")
+	assert.Equal(t, cfg.Timeouts.ReadHeader, 5*time.Second)
+	assert.Equal(t, cfg.Shutdown.Timeout, 30*time.Second)
+	assert.Equal(t, cfg.DB.MaxOpenConns, 25)
+	assert.Equal(t, cfg.Security, defaultSecurity)
+}
+
+func TestLoadConfigDev(t *testing.T) {
+	cfg, err := loadConfig([]string{"-dev", "-hsts-max-age", "24h"}, env(nil))
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.UIDir, "./ui")
+	assert.Equal(t, cfg.Security.HSTS.MaxAge, time.Duration(0))
+	assert.Equal(t, cfg.CursorSecret, "")
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := writeFile(t, "snippetbox.toml", `
+addr = ":5000"
+driver = "postgres"
+log-level = "debug"
+trusted_proxies = ["10.0.0.0/8", "192.0.2.1"]
+
+[limit]
+read-rps = 3
+read-burst = 6
+idle = "1m"
+
+[tls]
+self-signed = true
+`)
+
+	cfg, err := loadConfig(
+		[]string{"-config", file, "-limit-read-burst", "7"},
+		env(withCursorSecret(map[string]string{
+			"SNIPPETBOX_ADDR":             ":6000",
+			"SNIPPETBOX_LIMIT_READ_BURST": "8",
+		})),
+	)
+	assert.NilError(t, err)
+
+	// The file beats the defaults, the environment the file and the command
+	// line everything.
+	assert.Equal(t, cfg.Driver, "postgres")
+	assert.Equal(t, cfg.Limits.Read.Rate, 3.0)
+	assert.Equal(t, cfg.Limits.Idle, time.Minute)
+	assert.Equal(t, cfg.LogLevel.String(), "DEBUG")
+	assert.Equal(t, cfg.Addr, ":6000")
+	assert.Equal(t, cfg.Limits.Read.Burst, 7)
+
+	assert.Equal(t, len(cfg.Limits.TrustedProxies), 2)
+	assert.Equal(t, cfg.TLSCert, "./tls/cert.pem")
+
+	cfg, err = loadConfig(nil, env(withCursorSecret(map[string]string{"SNIPPETBOX_CONFIG": file})))
+	assert.NilError(t, err)
+	assert.Equal(t, cfg.Addr, ":5000")
+}
+
+func TestLoadConfigSecretFiles(t *testing.T) {
+	dsnFile := writeFile(t, "dsn", "web:s3cret@/snippetbox?parseTime=true\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantDSN string
+		wantErr string
+	}{
+		{
+			name:    "Flag",
+			args:    []string{"-dsn-file", dsnFile},
+			wantDSN: "web:s3cret@/snippetbox?parseTime=true",
+		},
+		{
+			name:    "Environment",
+			env:     map[string]string{"SNIPPETBOX_DSN_FILE": dsnFile},
+			wantDSN: "web:s3cret@/snippetbox?parseTime=true",
+		},
+		{
+			name:    "Flag Beats Environment File",
+			args:    []string{"-dsn", "web:other@/snippetbox"},
+			env:     map[string]string{"SNIPPETBOX_DSN_FILE": dsnFile},
+			wantDSN: "web:other@/snippetbox",
+		},
+		{
+			name:    "Both On The Command Line",
+			args:    []string{"-dsn", "web:other@/snippetbox", "-dsn-file", dsnFile},
+			wantErr: "set only one of dsn and dsn-file",
+		},
+		{
+			name:    "Missing File",
+			args:    []string{"-cursor-secret-file", filepath.Join(t.TempDir(), "missing")},
+			wantErr: "no such file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(tt.args, env(withCursorSecret(tt.env)))
+
+			if tt.wantErr != "" {
+				assert.Equal(t, err != nil, true)
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, cfg.DSN, tt.wantDSN)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{
+			name:    "Unknown Driver",
+			args:    []string{"-driver", "sqlite"},
+			wantErr: `unsupported database driver "sqlite"`,
+		},
+		{
+			name:    "Bad Environment Value",
+			env:     map[string]string{"SNIPPETBOX_LIMIT_READ_BURST": "lots"},
+			wantErr: "SNIPPETBOX_LIMIT_READ_BURST",
+		},
+		{
+			name:    "Unknown Setting",
+			file:    `adress = ":4000"`,
+			wantErr: `unknown setting "adress"`,
+		},
+		{
+			name:    "No Cursor Secret",
+			wantErr: "cursor-secret is required unless -dev is set",
+		},
+		{
+			name:    "No Time To Drain",
+			args:    []string{"-shutdown-timeout", "0s"},
+			wantErr: "shutdown-timeout must be positive",
+		},
+		{
+			name:    "TLS Key Without Certificate",
+			args:    []string{"-tls-key", "key.pem"},
+			wantErr: "tls-cert and tls-key must be set together",
+		},
+		{
+			name:    "HSTS Preload Without Subdomains",
+			args:    []string{"-hsts-preload"},
+			wantErr: "hsts-preload needs hsts-include-subdomains",
+		},
+		{
+			name:    "Unknown Referrer Policy",
+			file:    `referrer_policy = "sometimes"`,
+			wantErr: `invalid referrer-policy "sometimes"`,
+		},
+		{
+			name:    "Relative Embed Route",
+			args:    []string{"-embed-routes", "/snippet/view/:id,snippet/raw/:id/:name"},
+			wantErr: `invalid embed route "snippet/raw/:id/:name"`,
+		},
+		{
+			name:    "Every Problem Reported",
+			args:    []string{"-log-format", "xml", "-trace-sample-ratio", "2"},
+			wantErr: `unknown log format "xml"` + "\n" + "trace-sample-ratio 2 is not between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "snippetbox.toml", tt.file))
+			}
+
+			_, err := loadConfig(args, env(tt.env))
+
+			assert.Equal(t, err != nil, true)
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg, err := loadConfig([]string{"-dsn", "web:s3cret@/snippetbox", "-addr", ":5000", "-limit-idle", "90s"}, env(withCursorSecret(nil)))
+	assert.NilError(t, err)
+
+	buf := new(bytes.Buffer)
+	cfg.print(buf)
+
+	assert.Equal(t, strings.Contains(buf.String(), "s3cret"), false)
+	assert.StringContains(t, buf.String(), `dsn = "REDACTED"`)
+	assert.StringContains(t, buf.String(), `addr = ":5000"`)
+	assert.StringContains(t, buf.String(), "limit-read-burst = 20\n")
+	assert.StringContains(t, buf.String(), `cursor-secret = "REDACTED"`)
+
+	// The output is a valid config file, apart from the redacted secrets.
+	printed := strings.ReplaceAll(buf.String(), `dsn = "REDACTED"`, "")
+
+	reloaded, err := loadConfig([]string{"-config", writeFile(t, "printed.toml", printed)}, env(withCursorSecret(nil)))
+	assert.NilError(t, err)
+	assert.Equal(t, reloaded.Addr, ":5000")
+	assert.Equal(t, reloaded.Limits.Idle, 90*time.Second)
+}
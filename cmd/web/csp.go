@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const cspNonceContextKey = contextKey("cspNonce")
+
+// cspReportPath receives the violation reports browsers send under the
+// Content-Security-Policy, and cspReportMaxBytes caps the size of one.
+const (
+	cspReportPath     = "/csp-report"
+	cspReportMaxBytes = 64 * 1024
+)
+
+// newCSPNonce returns a random nonce for one response. Only script elements
+// carrying it run, so markup injected into a page can't start a script.
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func withCSPNonce(r *http.Request, nonce string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), cspNonceContextKey, nonce))
+}
+
+// cspNonce returns the nonce the policy sent with the response to r allows
+// scripts with.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
+
+// contentSecurityPolicy returns the policy for a response whose scripts carry
+// nonce and which frameAncestors may frame. Everything a page uses is served
+// by the app itself.
+func contentSecurityPolicy(nonce, frameAncestors string) string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + nonce + "'",
+		"style-src 'self'",
+		"font-src 'self'",
+		"img-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors " + frameAncestors,
+		"report-uri " + cspReportPath,
+		"report-to csp-endpoint",
+	}, "; ")
+}
+
+// cspViolation is what a browser tells about content blocked by the policy.
+// Browsers using report-uri send kebab-case fields wrapped in "csp-report",
+// those using the Reporting API camelCase ones in the body of each report.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	DocumentURL        string `json:"documentURL"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	BlockedURI         string `json:"blocked-uri"`
+	BlockedURL         string `json:"blockedURL"`
+	SourceFile         string `json:"source-file"`
+	SourceFileCamel    string `json:"sourceFile"`
+	LineNumber         int    `json:"line-number"`
+	LineNumberCamel    int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+	Sample             string `json:"script-sample"`
+	SampleCamel        string `json:"sample"`
+}
+
+func (v cspViolation) attrs() []any {
+	return []any{
+		slog.String("document", firstNonEmpty(v.DocumentURI, v.DocumentURL)),
+		slog.String("directive", firstNonEmpty(v.EffectiveDirective, v.ViolatedDirective)),
+		slog.String("blocked", firstNonEmpty(v.BlockedURI, v.BlockedURL)),
+		slog.String("source", firstNonEmpty(v.SourceFile, v.SourceFileCamel)),
+		slog.Int("line", max(v.LineNumber, v.LineNumberCamel)),
+		slog.String("disposition", v.Disposition),
+		slog.String("sample", firstNonEmpty(v.Sample, v.SampleCamel)),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseCSPReport reads the violations in a report, which is a single object
+// from report-uri or a list of reports from the Reporting API.
+func parseCSPReport(body []byte) ([]cspViolation, error) {
+	body = bytes.TrimSpace(body)
+
+	if bytes.HasPrefix(body, []byte("[")) {
+		var reports []struct {
+			Type string       `json:"type"`
+			Body cspViolation `json:"body"`
+		}
+
+		err := json.Unmarshal(body, &reports)
+		if err != nil {
+			return nil, err
+		}
+
+		violations := []cspViolation{}
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				violations = append(violations, report.Body)
+			}
+		}
+		return violations, nil
+	}
+
+	var report struct {
+		Violation cspViolation `json:"csp-report"`
+	}
+
+	err := json.Unmarshal(body, &report)
+	if err != nil {
+		return nil, err
+	}
+
+	return []cspViolation{report.Violation}, nil
+}
+
+// cspReport logs the violations a browser reports.
+func (app *application) cspReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cspReportMaxBytes))
+	if err != nil {
+		app.formError(w, r, err)
+		return
+	}
+
+	violations, err := parseCSPReport(body)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	for _, v := range violations {
+		app.requestLogger(r).Warn("content security policy violation", v.attrs()...)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+var (
+	policyNonceRX  = regexp.MustCompile(`'nonce-([^']+)'`)
+	htmxScriptRX   = regexp.MustCompile(`<script src='(/static/js/htmx\.min\.[0-9a-f]{12}\.js)'`)
+	configScriptRX = regexp.MustCompile(`<script src='(/static/js/config\.[0-9a-f]{12}\.js)'`)
+)
+
+func TestCSPNonce(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	seen := map[string]bool{}
+
+	for _, path := range []string{"/", "/snippet/create", "/snippets/search", "/tags/haiku", "/missing"} {
+		t.Run(path, func(t *testing.T) {
+			_, header, body := ts.get(t, path)
+
+			m := policyNonceRX.FindStringSubmatch(header.Get("Content-Security-Policy"))
+			if m == nil {
+				t.Fatalf("no nonce in %q", header.Get("Content-Security-Policy"))
+			}
+
+			nonce := m[1]
+			assert.Equal(t, seen[nonce], false)
+			seen[nonce] = true
+
+			scripts := strings.Count(body, "<script")
+			assert.Equal(t, scripts > 0, true)
+			assert.Equal(t, strings.Count(body, "nonce='"+nonce+"'"), scripts)
+
+			assert.Equal(t, strings.Contains(body, "unpkg.com"), false)
+			assert.Equal(t, strings.Contains(body, "fonts.googleapis.com"), false)
+		})
+	}
+}
+
+func TestCSPReportOnly(t *testing.T) {
+	app := newTestApplication(t)
+	app.security.CSPReportOnly = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, header, _ := ts.get(t, "/")
+
+	assert.Equal(t, header.Get("Content-Security-Policy"), "")
+	assert.StringContains(t, header.Get("Content-Security-Policy-Report-Only"), "script-src 'self' 'nonce-")
+}
+
+func TestCSPReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantLogged  []string
+	}{
+		{
+			name:        "Report URI",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": {"document-uri": "https://example.com/", "violated-directive": "script-src-elem", "blocked-uri": "https://evil.example/x.js", "line-number": 7, "disposition": "enforce"}}`,
+			wantCode:    http.StatusNoContent,
+			wantLogged:  []string{"document=https://example.com/", "directive=script-src-elem", "blocked=https://evil.example/x.js", "line=7", "disposition=enforce"},
+		},
+		{
+			name:        "Reporting API",
+			contentType: "application/reports+json",
+			body:        `[{"type": "csp-violation", "body": {"documentURL": "https://example.com/", "effectiveDirective": "style-src", "blockedURL": "inline", "sample": "color: red", "disposition": "report"}}, {"type": "deprecation", "body": {}}]`,
+			wantCode:    http.StatusNoContent,
+			wantLogged:  []string{"directive=style-src", "blocked=inline", `sample="color: red"`, "disposition=report"},
+		},
+		{
+			name:        "Malformed",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": `,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Too Large",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": {"script-sample": "` + strings.Repeat("x", cspReportMaxBytes) + `"}}`,
+			wantCode:    http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			r := httptest.NewRequest(http.MethodPost, cspReportPath, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+
+			violations := strings.Count(logs.String(), "content security policy violation")
+			assert.Equal(t, violations, min(len(tt.wantLogged), 1))
+
+			for _, want := range tt.wantLogged {
+				assert.StringContains(t, logs.String(), want)
+			}
+		})
+	}
+}
+
+func TestListingScripts(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, path := range []string{"/", "/snippets/search", "/tags/haiku"} {
+		t.Run(path, func(t *testing.T) {
+			_, _, body := ts.get(t, path)
+
+			// htmx comes from the hashed static files like the rest of the
+			// scripts, and config.js after it.
+			for _, rx := range []*regexp.Regexp{htmxScriptRX, configScriptRX} {
+				m := rx.FindStringSubmatch(body)
+				if m == nil {
+					t.Fatalf("no script matching %q", rx)
+				}
+
+				code, header, _ := ts.get(t, m[1])
+				assert.Equal(t, code, http.StatusOK)
+				assert.Equal(t, header.Get("Cache-Control"), "public, max-age=31536000, immutable")
+			}
+
+			assert.Equal(t, strings.Index(body, "js/htmx.min.") < strings.Index(body, "js/config."), true)
+		})
+	}
+}
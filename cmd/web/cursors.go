@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"snippetbox.bimasenaputra/internal/models"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorCodec turns models.Cursor values into opaque tokens for URLs. Tokens
+// are signed so that clients can't forge positions the UI never handed out.
+type cursorCodec struct {
+	key []byte
+}
+
+func (c *cursorCodec) encode(cursor *models.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	direction := "n"
+	if cursor.Prev {
+		direction = "p"
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s%d", direction, cursor.ID)))
+
+	return payload + "." + c.sign(payload)
+}
+
+func (c *cursorCodec) decode(token string) (*models.Cursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return nil, errInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) < 2 {
+		return nil, errInvalidCursor
+	}
+
+	id, err := strconv.Atoi(string(raw[1:]))
+	if err != nil || id < 1 {
+		return nil, errInvalidCursor
+	}
+
+	switch raw[0] {
+	case 'n':
+		return &models.Cursor{ID: id}, nil
+	case 'p':
+		return &models.Cursor{ID: id, Prev: true}, nil
+	default:
+		return nil, errInvalidCursor
+	}
+}
+
+func (c *cursorCodec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/models"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec := &cursorCodec{key: []byte("key")}
+
+	tests := []struct {
+		name string
+		input *models.Cursor
+	} {
+		{
+			name: "Next",
+			input: &models.Cursor{ID: 42},
+		},
+		{
+			name: "Prev",
+			input: &models.Cursor{ID: 7, Prev: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := codec.decode(codec.encode(test.input))
+			assert.NilError(t, err)
+			assert.Equal(t, *actual, *test.input)
+		})
+	}
+
+	t.Run("Other Key", func(t *testing.T) {
+		token := (&cursorCodec{key: []byte("other")}).encode(&models.Cursor{ID: 42})
+		_, err := codec.decode(token)
+		assert.Equal(t, errors.Is(err, errInvalidCursor), true)
+	})
+
+	t.Run("Tampered Payload", func(t *testing.T) {
+		token := codec.encode(&models.Cursor{ID: 42})
+		_, err := codec.decode("bjQz" + token[4:])
+		assert.Equal(t, errors.Is(err, errInvalidCursor), true)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := codec.decode("")
+		assert.Equal(t, errors.Is(err, errInvalidCursor), true)
+		assert.Equal(t, codec.encode(nil), "")
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// templateReloader rebuilds the template cache whenever a file under html/
+// changes. It's only used with -dev; production renders from the cache built
+// at startup.
+type templateReloader struct {
+	fsys   fs.FS
+	assets *staticAssets
+
+	mu    sync.Mutex
+	stamp string
+	cache map[string]*template.Template
+}
+
+// load returns the templates as they are on disk, parsing them again if any
+// file was added, removed or modified since the last call.
+func (tr *templateReloader) load() (map[string]*template.Template, error) {
+	stamp, err := htmlStamp(tr.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.cache != nil && stamp == tr.stamp {
+		return tr.cache, nil
+	}
+
+	cache, err := newTemplateCache(tr.fsys, tr.assets)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.stamp, tr.cache = stamp, cache
+	return cache, nil
+}
+
+// htmlStamp sums up the name, size and modification time of every template,
+// which is enough to notice an edit without reading the files.
+func htmlStamp(fsys fs.FS) (string, error) {
+	var b strings.Builder
+
+	err := fs.WalkDir(fsys, "html", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	return b.String(), err
+}
+
+// templates returns the template set to render from.
+func (app *application) templates() (map[string]*template.Template, error) {
+	if app.reloader == nil {
+		return app.templateCache, nil
+	}
+
+	return app.reloader.load()
+}
+
+// templateError reports a template that failed to parse or execute. In
+// development it shows the error next to the offending source.
+func (app *application) templateError(w http.ResponseWriter, r *http.Request, err error) {
+	if !app.dev {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.requestLogger(r).Error(err.Error())
+
+	data := devErrorData{Message: err.Error()}
+
+	if m := templateErrorPosition.FindStringSubmatch(err.Error()); m != nil && app.reloader != nil {
+		data.Line, _ = strconv.Atoi(m[2])
+		data.File, data.Source = templateSource(app.reloader.fsys, m[1], data.Line)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	err = devErrorPage.Execute(w, data)
+	if err != nil {
+		app.requestLogger(r).Error(err.Error())
+	}
+}
+
+// templateErrorPosition matches the start of errors from text/template, such
+// as "template: view.html:12:5: executing ...".
+var templateErrorPosition = regexp.MustCompile(`template: ([^:\s]+):(\d+)(?::\d+)?:`)
+
+// sourceContext is how many lines are shown either side of an error.
+const sourceContext = 5
+
+type devErrorData struct {
+	Message string
+	File    string
+	Line    int
+	Source  []sourceLine
+}
+
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// templateSource finds the template file called name and returns its path and
+// the lines around line. Templates are named after their file, without the
+// directory.
+func templateSource(fsys fs.FS, name string, line int) (string, []sourceLine) {
+	var file string
+
+	fs.WalkDir(fsys, "html", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && path.Base(p) == name {
+			file = p
+			return fs.SkipAll
+		}
+		return err
+	})
+
+	if file == "" {
+		return "", nil
+	}
+
+	b, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return file, nil
+	}
+
+	lines := strings.Split(string(b), "\n")
+	source := []sourceLine{}
+
+	for i := max(line-sourceContext, 1); i <= min(line+sourceContext, len(lines)); i++ {
+		source = append(source, sourceLine{Number: i, Text: lines[i-1], Current: i == line})
+	}
+
+	return file, source
+}
+
+// noCache turns off HTTP caching in development, so edited templates and
+// static files show up on the next reload. Conditional headers are dropped so
+// nothing answers 304 Not Modified.
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+var devErrorPage = template.Must(template.New("error").Parse(`<!doctype html>
+<html lang='en'>
+<head>
+    <meta charset='utf-8'>
+    <title>Template error - Snippetbox</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; color: #23232e; }
+        h1 { color: #c0392b; }
+        pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+        .message { white-space: pre-wrap; }
+        .current { background: #f9d6d5; font-weight: bold; }
+        .number { color: #999; user-select: none; }
+    </style>
+</head>
+<body>
+    <h1>Template error</h1>
+    {{with .File}}<p><strong>{{.}}</strong>{{with $.Line}}, line {{.}}{{end}}</p>{{end}}
+    <pre class='message'>{{.Message}}</pre>
+    {{with .Source}}
+    <pre>{{range .}}<span{{if .Current}} class='current'{{end}}><span class='number'>{{printf "%4d" .Number}}</span>  {{.Text}}
+</span>{{end}}</pre>
+    {{end}}
+    <p>The page is parsed again when you save a template, so reload to try again.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/ui"
+)
+
+// devFiles is a minimal ui directory for exercising template reloading.
+func devFiles(home string) fstest.MapFS {
+	return fstest.MapFS{
+		"html/base.html":           {Data: []byte(`{{define "base"}}<title>{{template "title" .}}</title>{{template "main" .}}{{end}}`)},
+		"html/partials/empty.html": {Data: []byte(`{{define "empty"}}{{end}}`)},
+		"html/pages/home.html":     {Data: []byte(home), ModTime: time.Unix(1, 0)},
+		"static/css/main.css":      {Data: []byte("body {}")},
+	}
+}
+
+func newDevApplication(t *testing.T, fsys fstest.MapFS) *application {
+	app := newTestApplication(t)
+
+	app.dev = true
+	app.static = newLiveStaticAssets(fsys)
+	app.reloader = &templateReloader{fsys: fsys, assets: app.static}
+
+	return app
+}
+
+func TestTemplateReloader(t *testing.T) {
+	fsys := devFiles(`{{define "title"}}Home{{end}}{{define "main"}}First{{end}}`)
+	tr := &templateReloader{fsys: fsys, assets: newLiveStaticAssets(fsys)}
+
+	first, err := tr.load()
+	assert.NilError(t, err)
+
+	again, err := tr.load()
+	assert.NilError(t, err)
+	assert.Equal(t, again["home.html"] == first["home.html"], true)
+
+	fsys["html/pages/home.html"] = &fstest.MapFile{
+		Data:    []byte(`{{define "title"}}Home{{end}}{{define "main"}}Second{{end}}`),
+		ModTime: time.Unix(2, 0),
+	}
+
+	edited, err := tr.load()
+	assert.NilError(t, err)
+	assert.Equal(t, edited["home.html"] == first["home.html"], false)
+
+	fsys["html/pages/home.html"] = &fstest.MapFile{
+		Data:    []byte(`{{define "main"}}{{if}}{{end}}`),
+		ModTime: time.Unix(3, 0),
+	}
+
+	_, err = tr.load()
+	assert.Equal(t, err != nil, true)
+}
+
+func TestDevTemplateError(t *testing.T) {
+	tests := []struct {
+		name     string
+		home     string
+		wantLine string
+		wantBody string
+	}{
+		{
+			name:     "Execution Error",
+			home:     "{{define \"title\"}}Home{{end}}\n{{define \"main\"}}\n<p>{{.Missing}}</p>\n{{end}}",
+			wantLine: "html/pages/home.html</strong>, line 3",
+			wantBody: "can&#39;t evaluate field Missing",
+		},
+		{
+			name:     "Parse Error",
+			home:     "{{define \"title\"}}Home{{end}}\n{{define \"main\"}}\n{{if}}\n{{end}}",
+			wantLine: "html/pages/home.html</strong>, line 3",
+			wantBody: "missing value for if",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newDevApplication(t, devFiles(tt.home))
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, header, body := ts.get(t, "/")
+
+			assert.Equal(t, code, http.StatusInternalServerError)
+			assert.Equal(t, header.Get("Content-Type"), "text/html; charset=utf-8")
+			assert.StringContains(t, body, tt.wantLine)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.StringContains(t, body, "class='current'")
+		})
+	}
+}
+
+func TestDevNoCache(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	etag := func() string {
+		_, header, _ := ts.get(t, "/snippet/view/1")
+		return header.Get("ETag")
+	}()
+	ts.Close()
+
+	staticFiles, err := fs.Sub(ui.Files, "static")
+	assert.NilError(t, err)
+
+	app.dev = true
+	app.static = newLiveStaticAssets(staticFiles)
+
+	ts = newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, path := range []string{"/snippet/view/1", "/static/css/main.css"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		assert.NilError(t, err)
+		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).Format(http.TimeFormat))
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+		assert.Equal(t, rs.Header.Get("Cache-Control"), "no-store")
+		assert.Equal(t, rs.Header.Get("ETag"), "")
+	}
+}
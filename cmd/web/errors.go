@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errorDetails explains each error status to the people who run into it.
+var errorDetails = map[int]string{
+	http.StatusBadRequest:            "The request couldn't be understood. Check the address or the form and try again.",
+	http.StatusNotFound:              "There's nothing here. The snippet may have expired, or the link may be mistyped.",
+	http.StatusMethodNotAllowed:      "That can't be done to this page.",
+	http.StatusRequestEntityTooLarge: "The request was larger than the server accepts.",
+	http.StatusUnprocessableEntity:   "Some of the fields need fixing.",
+	http.StatusTooManyRequests:       "You're making requests too quickly. Please wait a moment and try again.",
+	http.StatusInternalServerError:   "Something went wrong on our side. Please try again later.",
+}
+
+// errorData describes an error to the error templates.
+type errorData struct {
+	Status    int
+	Title     string
+	Detail    string
+	RequestID string
+}
+
+// problem is an RFC 9457 problem details object.
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// errorPage reports an error in the form the client asked for: problem
+// details for API clients, a fragment for htmx and otherwise a full page.
+// Each quotes the request ID logRequest set, so that users reporting a
+// problem can point us at its log records.
+func (app *application) errorPage(w http.ResponseWriter, r *http.Request, status int) {
+	data := &errorData{
+		Status:    status,
+		Title:     http.StatusText(status),
+		Detail:    errorDetails[status],
+		RequestID: w.Header().Get("X-Request-ID"),
+	}
+
+	switch {
+	case wantsJSON(r):
+		app.writeProblem(w, r, data, nil)
+	case isHtmx(r):
+		app.renderError(w, r, "error_fragment.html", data)
+	default:
+		app.renderError(w, r, "error.html", data)
+	}
+}
+
+// renderError renders an error template. It can't report its own failure
+// through errorPage, so it falls back to plain text.
+func (app *application) renderError(w http.ResponseWriter, r *http.Request, page string, data *errorData) {
+	buf := new(bytes.Buffer)
+
+	cache, err := app.templates()
+	if err == nil {
+		ts, ok := cache[page]
+		if !ok {
+			err = errors.New("the template " + page + " does not exist")
+		} else {
+			err = ts.ExecuteTemplate(buf, "base", &templateData{Error: data, CSPNonce: cspNonce(r)})
+		}
+	}
+
+	if err != nil {
+		app.requestLogger(r).Error("rendering error page", "error", err.Error())
+
+		message := data.Title
+		if data.RequestID != "" {
+			message += "\nRequest ID: " + data.RequestID
+		}
+
+		http.Error(w, message, data.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(data.Status)
+	buf.WriteTo(w)
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, data *errorData, fieldErrors map[string]string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+
+	app.writeJSON(w, data.Status, problem{
+		Type:      "about:blank",
+		Title:     data.Title,
+		Status:    data.Status,
+		Detail:    data.Detail,
+		Instance:  r.URL.Path,
+		RequestID: data.RequestID,
+		Errors:    fieldErrors,
+	})
+}
+
+// failedValidation shows a form again with its errors, or lists them in
+// problem details for API clients.
+func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, page string, data *templateData, fieldErrors map[string]string) {
+	if wantsJSON(r) {
+		status := http.StatusUnprocessableEntity
+
+		app.writeProblem(w, r, &errorData{
+			Status:    status,
+			Title:     http.StatusText(status),
+			Detail:    errorDetails[status],
+			RequestID: w.Header().Get("X-Request-ID"),
+		}, fieldErrors)
+		return
+	}
+
+	app.render(w, r, page, http.StatusUnprocessableEntity, data)
+}
+
+// formError reports a form that couldn't be parsed, telling bodies cut off
+// by http.MaxBytesReader apart from malformed ones.
+func (app *application) formError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		app.clientError(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	app.clientError(w, r, http.StatusBadRequest)
+}
+
+// wantsJSON reports whether the client prefers JSON to HTML, going by the
+// quality values in its Accept header. Wildcards count for neither, so
+// browsers and clients that send no preference get HTML.
+func wantsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json", "application/problem+json":
+			jsonQ = max(jsonQ, q)
+		case "text/html", "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
+}
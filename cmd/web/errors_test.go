@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"text/html;q=0.5, application/json", true},
+		{"application/json;q=0.5, text/html", false},
+		{"application/json;q=0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			assert.Equal(t, wantsJSON(r), tt.want)
+		})
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	app := newTestApplication(t)
+
+	panics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/panic", app.logRequest(app.recoverPanic(panics)))
+	mux.Handle("/", app.routes())
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		wantStatus int
+	}{
+		{
+			name:       "Not Found",
+			method:     http.MethodGet,
+			path:       "/snippet/view/99",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Method Not Allowed",
+			method:     http.MethodDelete,
+			path:       "/snippet/create",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "Bad Request",
+			method:     http.MethodGet,
+			path:       "/?page=first",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Too Large",
+			method:     http.MethodPost,
+			path:       "/snippets/search",
+			form:       url.Values{"query": {strings.Repeat("a", 5000)}},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "Internal Server Error",
+			method:     http.MethodGet,
+			path:       "/panic",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, client := range []string{"Browser", "API", "htmx"} {
+			t.Run(tt.name+"/"+client, func(t *testing.T) {
+				var body *strings.Reader
+				if tt.form != nil {
+					body = strings.NewReader(tt.form.Encode())
+				} else {
+					body = strings.NewReader("")
+				}
+
+				req, err := http.NewRequest(tt.method, ts.URL+tt.path, body)
+				assert.NilError(t, err)
+
+				if tt.form != nil {
+					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				}
+
+				switch client {
+				case "Browser":
+					req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+				case "API":
+					req.Header.Set("Accept", "application/json")
+				case "htmx":
+					req.Header.Set("HX-Request", "true")
+				}
+
+				rs, err := ts.Client().Do(req)
+				assert.NilError(t, err)
+				defer rs.Body.Close()
+
+				assert.Equal(t, rs.StatusCode, tt.wantStatus)
+
+				id := rs.Header.Get("X-Request-ID")
+				title := http.StatusText(tt.wantStatus)
+
+				switch client {
+				case "Browser":
+					page := readBody(t, rs)
+					assert.Equal(t, rs.Header.Get("Content-Type"), "text/html; charset=utf-8")
+					assert.StringContains(t, page, "<title>"+title+" - Snippetbox</title>")
+					assert.StringContains(t, page, "<nav>")
+					assert.StringContains(t, page, template.HTMLEscapeString(errorDetails[tt.wantStatus]))
+					assert.StringContains(t, page, "Request ID: <code>"+id+"</code>")
+				case "API":
+					var got problem
+					assert.Equal(t, rs.Header.Get("Content-Type"), "application/problem+json")
+					assert.NilError(t, json.NewDecoder(rs.Body).Decode(&got))
+					assert.Equal(t, got.Status, tt.wantStatus)
+					assert.Equal(t, got.Title, title)
+					assert.Equal(t, got.Instance, strings.SplitN(tt.path, "?", 2)[0])
+					assert.Equal(t, got.RequestID, id)
+				case "htmx":
+					fragment := readBody(t, rs)
+					assert.StringContains(t, fragment, "<div id='response-div' class='error' role='alert'>")
+					assert.StringContains(t, fragment, "Request ID: "+id)
+					assert.Equal(t, strings.Contains(fragment, "<nav>"), false)
+				}
+			})
+		}
+	}
+
+}
+
+func TestRateLimitErrorPage(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = rateLimitConfig{Search: ratelimit.Budget{Rate: 0.001, Burst: 1}, Idle: time.Minute}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/snippets/search?q=pond")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, header, page := ts.get(t, "/snippets/search?q=pond")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After") != "", true)
+	assert.StringContains(t, page, template.HTMLEscapeString(errorDetails[http.StatusTooManyRequests]))
+}
+
+func TestFailedValidation(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/snippets/search", strings.NewReader("query="))
+	assert.NilError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	rs, err := ts.Client().Do(req)
+	assert.NilError(t, err)
+	defer rs.Body.Close()
+
+	var got problem
+	assert.NilError(t, json.NewDecoder(rs.Body).Decode(&got))
+
+	assert.Equal(t, rs.StatusCode, http.StatusUnprocessableEntity)
+	assert.Equal(t, got.Status, http.StatusUnprocessableEntity)
+	assert.Equal(t, got.Errors["query"], "This field cannot be blank")
+
+	// Browsers still get the form back with its errors.
+	code, _, page := ts.post(t, "/snippets/search", bytes.NewBufferString("query="))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, page, "This field cannot be blank")
+}
+
+func readBody(t *testing.T, rs *http.Response) string {
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
@@ -1,16 +1,139 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"snippetbox.bimasenaputra/internal/models"
 	"snippetbox.bimasenaputra/internal/validator"
 )
 
 type createSnippetForm struct {
 	Title string
-	Content string
+	Files []*models.SnippetFile
 	Expires int
+	Tags string
+	// Parent is the ID of the snippet being forked, or 0.
+	Parent int
 	validator.Validator
 }
 
+const (
+	maxFiles = 10
+	maxFileNameChars = 100
+)
+
+// languages are the file languages offered on the create form.
+var languages = []string{
+	"plaintext", "bash", "css", "dockerfile", "go", "html", "javascript",
+	"json", "markdown", "python", "sql", "toml", "yaml",
+}
+
+var languageByExtension = map[string]string{
+	".sh": "bash",
+	".css": "css",
+	".go": "go",
+	".html": "html",
+	".js": "javascript",
+	".json": "json",
+	".md": "markdown",
+	".py": "python",
+	".sql": "sql",
+	".toml": "toml",
+	".yaml": "yaml",
+	".yml": "yaml",
+}
+
+// detectLanguage guesses the language of a file from its name.
+func detectLanguage(name string) string {
+	if strings.EqualFold(name, "Dockerfile") {
+		return "dockerfile"
+	}
+
+	if language, ok := languageByExtension[strings.ToLower(path.Ext(name))]; ok {
+		return language
+	}
+
+	return "plaintext"
+}
+
+// parseFiles reads the repeated file_name, file_language and file_content
+// fields of the create form, in the order they were submitted.
+func parseFiles(form url.Values) ([]*models.SnippetFile, error) {
+	names, languages, contents := form["file_name"], form["file_language"], form["file_content"]
+
+	if len(names) != len(languages) || len(names) != len(contents) {
+		return nil, errors.New("mismatched file fields")
+	}
+
+	files := []*models.SnippetFile{}
+
+	for i := range names {
+		files = append(files, &models.SnippetFile{
+			Name: strings.TrimSpace(names[i]),
+			Language: languages[i],
+			Content: contents[i],
+		})
+	}
+
+	return files, nil
+}
+
+// checkFiles validates the files of form, filling in missing languages.
+// Errors for the i-th file are keyed file.i.name and file.i.content.
+func (form *createSnippetForm) checkFiles() {
+	form.CheckField(len(form.Files) > 0, "files", "A snippet needs at least one file")
+	form.CheckField(len(form.Files) <= maxFiles, "files", fmt.Sprintf("A snippet cannot have more than %d files", maxFiles))
+
+	seen := map[string]bool{}
+
+	for i, f := range form.Files {
+		name := fmt.Sprintf("file.%d.name", i)
+
+		form.CheckField(validator.NotBlank(f.Name), name, "This field cannot be blank")
+		form.CheckField(validator.MaxChars(f.Name, maxFileNameChars), name, fmt.Sprintf("This field cannot be more than %d characters long", maxFileNameChars))
+		form.CheckField(f.Name == "" || validator.Matches(f.Name, validator.FileNameRX), name, "File names can only contain letters, digits and . _ -")
+		form.CheckField(!seen[f.Name], name, "File names must be unique")
+		form.CheckField(validator.NotBlank(f.Content), fmt.Sprintf("file.%d.content", i), "This field cannot be blank")
+
+		seen[f.Name] = true
+
+		if f.Language == "" {
+			f.Language = detectLanguage(f.Name)
+		}
+		form.CheckField(validator.PermittedValue(f.Language, languages...), fmt.Sprintf("file.%d.language", i), "Unknown language")
+	}
+}
+
+const (
+	maxTags = 5
+	maxTagChars = 20
+)
+
+// parseTags splits a comma or space separated tag list into lowercase,
+// de-duplicated tag names.
+func parseTags(value string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range fields {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
 type searchForm struct {
 	Query string
 	validator.Validator
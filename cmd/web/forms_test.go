@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected string
+	} {
+		{
+			name: "Empty",
+			input: "  ",
+			expected: "",
+		},
+		{
+			name: "Commas And Spaces",
+			input: "k8s, sql  bash,,go",
+			expected: "k8s sql bash go",
+		},
+		{
+			name: "Lowercase And Duplicates",
+			input: "SQL sql Sql bash",
+			expected: "sql bash",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := parseTags(test.input)
+			assert.Equal(t, strings.Join(actual, " "), test.expected)
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected string
+	} {
+		{
+			name: "Extension",
+			input: "main.go",
+			expected: "go",
+		},
+		{
+			name: "Uppercase Extension",
+			input: "SETUP.SH",
+			expected: "bash",
+		},
+		{
+			name: "Dockerfile",
+			input: "Dockerfile",
+			expected: "dockerfile",
+		},
+		{
+			name: "Unknown",
+			input: "go.mod",
+			expected: "plaintext",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, detectLanguage(test.input), test.expected)
+		})
+	}
+}
@@ -1,111 +1,281 @@
 package main
 
 import (
-	"database/sql"
-	"errors"
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"snippetbox.bimasenaputra/internal/models"
 	"snippetbox.bimasenaputra/internal/validator"
 )
 
+// maxSnippetBytes caps the size of a submitted snippet, all files included.
+const maxSnippetBytes = 512 * 1024
+
+// maxForksShown caps how many forks are listed under a snippet.
+const maxForksShown = 20
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	filter, err := app.snippetFilter(r)
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	minId, err := app.snippets.GetMinID()
+	page, err := app.snippetPage(r, filter)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	templateData := &templateData {
-		Snippets: snippets,
-		HasPrev: false,
-		HasNext: snippets[len(snippets)-1].ID != minId,
+	cloud, err := app.tagCloud(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	app.render(w, "home.html", http.StatusOK, templateData)
+	templateData := app.newPageData(page, url.Values{}, "/", "/snippets/latest")
+	templateData.TagCloud = cloud
+
+	app.render(w, r, "home.html", http.StatusOK, templateData)
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFoundError(w)
+	forks, err := app.snippets.Forks(r.Context(), snippet.ID, maxForksShown)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	if !app.dev {
+		w.Header().Set("Cache-Control", "no-cache")
+		if notModified(w, r, app.snippetETag(snippet, forks)) {
+			return
+		}
+	}
 
+	tags, err := app.tags.ForSnippet(r.Context(), snippet.ID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			app.notFoundError(w)
-		} else {
-			app.serverError(w, err)
-		}
+		app.serverError(w, r, err)
 		return
 	}
 
 	templateData := &templateData {
 		Snippet: snippet,
+		Forks: forks,
+		Tags: tags,
+	}
+
+	app.render(w, r, "view.html", http.StatusOK, templateData)
+}
+
+// snippetFork shows the create form prefilled with a copy of a snippet. The
+// new snippet records the original as its parent when it's published.
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := app.tags.ForSnippet(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	files := make([]*models.SnippetFile, len(snippet.Files))
+	for i, f := range snippet.Files {
+		file := *f
+		files[i] = &file
+	}
+
+	templateData := &templateData {
+		Form: &createSnippetForm {
+			Title: snippet.Title,
+			Files: files,
+			Expires: 365,
+			Tags: strings.Join(tags, ", "),
+			Parent: snippet.ID,
+		},
+	}
+	app.render(w, r, "create.html", http.StatusOK, templateData)
+}
+
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	file := snippet.File(params.ByName("name"))
+	if file == nil {
+		app.notFoundError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(file.Content))
+}
+
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for _, file := range snippet.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name: fmt.Sprintf("snippet-%d/%s", snippet.ID, file.Name),
+			Method: zip.Deflate,
+			Modified: snippet.Created,
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		_, err = fw.Write([]byte(file.Content))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	app.render(w, "view.html", http.StatusOK, templateData)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippet-%d.zip"`, snippet.ID))
+	buf.WriteTo(w)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	templateData := &templateData {
-		Form: &createSnippetForm { Expires: 365, },
+		Form: &createSnippetForm {
+			Files: []*models.SnippetFile{{}},
+			Expires: 365,
+		},
 	}
-	app.render(w, "create.html", http.StatusOK, templateData)
+	app.render(w, r, "create.html", http.StatusOK, templateData)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	r.Body = http.MaxBytesReader(w, r.Body, maxSnippetBytes)
 
 	err := r.ParseForm()
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, r, err)
+		return
+	}
+
+	files, err := parseFiles(r.PostForm)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
 	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
+	var parent int
+
+	if r.PostForm.Get("parent") != "" {
+		parent, err = strconv.Atoi(r.PostForm.Get("parent"))
+		if err != nil || parent < 1 {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+	}
+
 	form := &createSnippetForm {
 		Title: r.PostForm.Get("title"),
-		Content: r.PostForm.Get("content"),
+		Files: files,
 		Expires: expires,
+		Tags: r.PostForm.Get("tags"),
+		Parent: parent,
 	}
+
+	// Without JavaScript the add and remove buttons submit the form, so
+	// apply the change and show the form again.
+	if r.PostForm.Has("add_file") {
+		form.Files = append(form.Files, &models.SnippetFile{})
+		app.render(w, r, "create.html", http.StatusOK, &templateData{Form: form})
+		return
+	}
+
+	if r.PostForm.Has("remove_file") {
+		i, err := strconv.Atoi(r.PostForm.Get("remove_file"))
+		if err != nil || i < 0 || i >= len(form.Files) {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+		form.Files = append(form.Files[:i], form.Files[i+1:]...)
+		app.render(w, r, "create.html", http.StatusOK, &templateData{Form: form})
+		return
+	}
+
+	tags := parseTags(form.Tags)
 	
 	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.checkFiles()
 	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(len(tags) <= maxTags, "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+
+	for _, tag := range tags {
+		form.CheckField(validator.MaxChars(tag, maxTagChars), "tags", fmt.Sprintf("Tags cannot be more than %d characters long", maxTagChars))
+		form.CheckField(validator.Matches(tag, validator.TagRX), "tags", "Tags can only contain letters, digits and + # . _ -")
+	}
+
+	// A fork may outlive its parent, so an expired parent is fine; only an
+	// ID that was never stored is refused. Drop it so that submitting again
+	// publishes a snippet of its own.
+	if form.Parent != 0 {
+		exists, err := app.snippets.Exists(r.Context(), form.Parent)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !exists {
+			form.AddFieldError("parent", fmt.Sprintf("Snippet #%d doesn't exist, so this will be published as a new snippet", form.Parent))
+			form.Parent = 0
+		}
+	}
 
 	if !form.Valid() {
 		templateData := &templateData {
 			Form: form,
 		}
-		app.render(w, "create.html", http.StatusUnprocessableEntity, templateData)
+		app.failedValidation(w, r, "create.html", templateData, form.FieldErrors)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, expires)
+	id, err := app.snippets.Insert(r.Context(), form.Title, form.Files, tags, expires, form.Parent)
 
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return 
 	}
 
@@ -113,73 +283,19 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 }
 
 func (app *application) snippetLatest(w http.ResponseWriter, r *http.Request) {
-	opt := r.URL.Query().Get("direction")
-	if strings.TrimSpace(opt) == "" {
-		app.clientError(w, http.StatusBadRequest)
+	filter, err := app.snippetFilter(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	page, err := app.snippetPage(r, filter)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.serverError(w, r, err)
 		return
 	}
 
-	if opt == "next" {
-		snippets, err := app.snippets.NextLatestPaging(id)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		minId, err := app.snippets.GetMinID()
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		maxId, err := app.snippets.GetMaxID()
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		templateData := &templateData{
-			Snippets: snippets,
-			HasNext: snippets[len(snippets)-1].ID != minId,
-			HasPrev: snippets[0].ID != maxId,
-		}
-
-		app.render(w, "snippets_home.html", http.StatusOK, templateData)
-	} else if opt == "prev" {
-		snippets, err := app.snippets.PrevLatestPaging(id)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		minId, err := app.snippets.GetMinID()
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		maxId, err := app.snippets.GetMaxID()
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		templateData := &templateData{
-			Snippets: snippets,
-			HasNext: snippets[len(snippets)-1].ID != minId,
-			HasPrev: snippets[0].ID != maxId,
-		}
-
-		app.render(w, "snippets_home.html", http.StatusOK, templateData)
-	} else {
-		app.clientError(w, http.StatusBadRequest)
-	}
+	app.render(w, r, "snippets_home.html", http.StatusOK, app.newPageData(page, url.Values{}, "/", "/snippets/latest"))
 }
 
 func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
@@ -190,116 +306,78 @@ func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
 				Query: "",
 			},
 		}
-		app.render(w, "search.html", http.StatusOK, templateData)
+		app.render(w, r, "search.html", http.StatusOK, templateData)
 		return
 	}
 
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if strings.TrimSpace(r.URL.Query().Get("id")) != "" && err != nil {
-		app.clientError(w, http.StatusBadRequest)
+	filter, err := app.snippetFilter(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	direction := r.URL.Query().Get("direction")
-	
-	if id == 0 || strings.TrimSpace(direction) == "" {
-		snippets, err := app.snippets.LatestContainsTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		if len(snippets) == 0 {
-			templateData := &templateData{
-				Snippets: snippets,
-				Form: &searchForm {
-					Query: query,
-				},
-			}
-			app.render(w, "search.html", http.StatusOK, templateData)
-			return
-		}
+	filter.Title = query
 
-		minId, err := app.snippets.GetMinIDByTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	page, err := app.snippetPage(r, filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-		templateData := &templateData{
-			Snippets: snippets,
-			Form: &searchForm {
-				Query: query,
-			},
-			HasPrev: false,
-			HasNext: snippets[len(snippets)-1].ID != minId,
-		}
+	templateData := app.newPageData(page, url.Values{"q": {query}}, "/snippets/search", "/snippets/search")
+	templateData.Form = &searchForm{
+		Query: query,
+	}
 
-		app.render(w, "search.html", http.StatusOK, templateData)
+	// Paging links are fetched by htmx and only need the results fragment;
+	// the same URLs opened directly get the full search page.
+	if isHtmx(r) {
+		app.render(w, r, "snippets_search.html", http.StatusOK, templateData)
 		return
 	}
 
-	if direction == "next" {
-		minId, err := app.snippets.GetMinIDByTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	app.render(w, r, "search.html", http.StatusOK, templateData)
+}
 
-		maxId, err := app.snippets.GetMaxIDByTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
 
-		snippets, err := app.snippets.NextLatestContainsTitle(id, query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	tag := params.ByName("name")
+	if !validator.MaxChars(tag, maxTagChars) || !validator.Matches(tag, validator.TagRX) {
+		app.notFoundError(w, r)
+		return
+	}
 
-		templateData := &templateData{
-			Snippets: snippets,
-			Form: &searchForm {
-				Query: query,
-			},
-			HasPrev: snippets[0].ID != maxId,
-			HasNext: snippets[len(snippets)-1].ID != minId,
-		}
+	filter, err := app.snippetFilter(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
 
-		app.render(w, "snippets_search.html", http.StatusOK, templateData)
-	} else if direction == "prev" {
-		minId, err := app.snippets.GetMinIDByTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	filter.Tag = tag
 
-		maxId, err := app.snippets.GetMaxIDByTitle(query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	page, err := app.snippetPage(r, filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-		snippets, err := app.snippets.PrevLatestContainsTitle(id, query)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	path := "/tags/" + url.PathEscape(tag)
+	templateData := app.newPageData(page, url.Values{}, path, path)
+	templateData.Tag = tag
 
-		templateData := &templateData{
-			Snippets: snippets,
-			Form: &searchForm {
-				Query: query,
-			},
-			HasPrev: snippets[0].ID != maxId,
-			HasNext: snippets[len(snippets)-1].ID != minId,
-		}
+	if isHtmx(r) {
+		app.render(w, r, "snippets_home.html", http.StatusOK, templateData)
+		return
+	}
 
-		app.render(w, "snippets_search.html", http.StatusOK, templateData)
-	} else {
-		app.clientError(w, http.StatusBadRequest)
+	templateData.TagCloud, err = app.tagCloud(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
+
+	app.render(w, r, "tag.html", http.StatusOK, templateData)
 }
 
 func (app *application) snippetSearchPost(w http.ResponseWriter, r *http.Request) {
@@ -307,7 +385,7 @@ func (app *application) snippetSearchPost(w http.ResponseWriter, r *http.Request
 
 	err := r.ParseForm()
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, r, err)
 		return
 	}
 
@@ -321,9 +399,9 @@ func (app *application) snippetSearchPost(w http.ResponseWriter, r *http.Request
 		templateData := &templateData {
 			Form: form,
 		}
-		app.render(w, "search.html", http.StatusUnprocessableEntity, templateData)
+		app.failedValidation(w, r, "search.html", templateData, form.FieldErrors)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/snippets/search?q=%s", form.Query), http.StatusSeeOther)
+	http.Redirect(w, r, "/snippets/search?q="+url.QueryEscape(form.Query), http.StatusSeeOther)
 }
\ No newline at end of file
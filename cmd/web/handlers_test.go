@@ -1,13 +1,18 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 
 	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/mocks"
+	"snippetbox.bimasenaputra/internal/models"
 )
 
 func TestHome(t *testing.T) {
@@ -16,8 +21,56 @@ func TestHome(t *testing.T) {
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	code, _, _ := ts.get(t, "/")
-	assert.Equal(t, code, http.StatusOK)
+	tests := []struct {
+		name string
+		path string
+		wantCode int
+		wantBody string
+	} {
+		{
+			name: "First Page",
+			path: "/",
+			wantCode: http.StatusOK,
+			wantBody: "Page 1 of 1",
+		},
+		{
+			name: "Page Size",
+			path: "/?size=25",
+			wantCode: http.StatusOK,
+			wantBody: "<option value='25'  selected >",
+		},
+		{
+			name: "Last Page",
+			path: "/?page=last",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Page Size Too Large",
+			path: "/?size=51",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "Zero Page Size",
+			path: "/?size=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid Page",
+			path: "/?page=first",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.path)
+			assert.Equal(t, code, test.wantCode)
+
+			if test.wantBody != "" {
+				assert.StringContains(t, body, test.wantBody)
+			}
+		})
+	}
 }
 
 func TestSnippetView(t *testing.T) {
@@ -38,6 +91,36 @@ func TestSnippetView(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name: "File Tabs",
+			path: "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<label for='file-tab-1'>notes.md</label>",
+		},
+		{
+			name: "Tags",
+			path: "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tags/haiku' class='tag'>haiku</a>",
+		},
+		{
+			name: "Forks",
+			path: "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/3'>#3 A frog jumps in</a>",
+		},
+		{
+			name: "Forked From",
+			path: "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: "Forked from <a href='/snippet/view/1'>#1 An old silent pond</a>",
+		},
+		{
+			name: "Forked From Expired",
+			path: "/snippet/view/4",
+			wantCode: http.StatusOK,
+			wantBody: "Forked from #2 (expired)",
+		},
 		{
 			name: "Non-existent ID",
 			path: "/snippet/view/2",
@@ -77,6 +160,141 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestSnippetViewETag(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), "no-cache")
+
+	etag := header.Get("ETag")
+	assert.Equal(t, strings.HasPrefix(etag, `W/"1-`), true)
+
+	_, other, _ := ts.get(t, "/snippet/view/3")
+	assert.Equal(t, other.Get("ETag") != etag, true)
+
+	tests := []struct {
+		name string
+		ifNoneMatch string
+		wantCode int
+	} {
+		{
+			name: "Current",
+			ifNoneMatch: etag,
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name: "Strong Form",
+			ifNoneMatch: `"other", ` + strings.TrimPrefix(etag, "W/"),
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name: "Stale",
+			ifNoneMatch: `W/"1-0000000000000000"`,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL + "/snippet/view/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("ETag"), etag)
+		})
+	}
+
+	app.uiVersion = "next release"
+	_, header, _ = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, header.Get("ETag") != etag, true)
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		path string
+		wantCode int
+		wantBody string
+	} {
+		{
+			name: "First File",
+			path: "/snippet/raw/1/haiku.txt",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name: "Second File",
+			path: "/snippet/raw/1/notes.md",
+			wantCode: http.StatusOK,
+			wantBody: "# Basho",
+		},
+		{
+			name: "Missing File",
+			path: "/snippet/raw/1/main.go",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "Missing Snippet",
+			path: "/snippet/raw/2/haiku.txt",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, header, body := ts.get(t, test.path)
+			assert.Equal(t, code, test.wantCode)
+
+			if test.wantBody != "" {
+				assert.Equal(t, body, test.wantBody)
+				assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+			}
+		})
+	}
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippet/download/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/zip")
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename="snippet-1.zip"`)
+
+	zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(zr.File), 2)
+	assert.Equal(t, zr.File[0].Name, "snippet-1/haiku.txt")
+	assert.Equal(t, zr.File[1].Name, "snippet-1/notes.md")
+
+	code, _, _ = ts.get(t, "/snippet/download/2")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
 func TestSnippetCreate(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -96,7 +314,9 @@ func TestSnippetCreatePost(t *testing.T) {
 	param := url.Values{}
 
     param.Set("title", "title")
-	param.Set("content", "content")
+	param.Set("file_name", "main.go")
+	param.Set("file_language", "")
+	param.Set("file_content", "content")
 	param.Set("expires", "7")
     payload1 := bytes.NewBufferString(param.Encode())
 
@@ -108,14 +328,27 @@ func TestSnippetCreatePost(t *testing.T) {
 	payload3 := bytes.NewBufferString(param.Encode())
 	param.Set("title", "title")
 
-	param.Set("content", "")
+	param.Set("file_content", "")
 	payload4 := bytes.NewBufferString(param.Encode())
-	param.Set("content", "content")
+	param.Set("file_content", "content")
 
 	param.Set("expires", "0")
 	payload5 := bytes.NewBufferString(param.Encode())
 	param.Set("expires", "7")
 
+	param.Set("tags", "K8s, sql bash sql")
+	payload7 := bytes.NewBufferString(param.Encode())
+
+	param.Set("tags", "a b c d e f")
+	payload8 := bytes.NewBufferString(param.Encode())
+
+	param.Set("tags", "k8s, sql!")
+	payload9 := bytes.NewBufferString(param.Encode())
+
+	param.Set("tags", strings.Repeat("a", 21))
+	payload10 := bytes.NewBufferString(param.Encode())
+	param.Set("tags", "")
+
 	param.Set("expires", "expires")
 	payload6 := bytes.NewBufferString(param.Encode())
 
@@ -123,6 +356,7 @@ func TestSnippetCreatePost(t *testing.T) {
 		name string
 		payload *bytes.Buffer
 		expected int
+		tags []string
 	} {
 		{
 			name: "Valid Request",
@@ -154,60 +388,327 @@ func TestSnippetCreatePost(t *testing.T) {
 			payload: payload6,
 			expected: http.StatusBadRequest,
 		},
+		{
+			name: "Valid Tags",
+			payload: payload7,
+			expected: http.StatusOK,
+			tags: []string{"k8s", "sql", "bash"},
+		},
+		{
+			name: "Too Many Tags",
+			payload: payload8,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Invalid Tag Characters",
+			payload: payload9,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Tag Has More Than 20 Characters",
+			payload: payload10,
+			expected: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			code, _, _ := ts.post(t, "/snippet/create", test.payload)
 			assert.Equal(t, code, test.expected)
+
+			if test.tags != nil {
+				got := app.snippets.(*mocks.SnippetModel).InsertedTags()
+				assert.Equal(t, strings.Join(got, " "), strings.Join(test.tags, " "))
+			}
 		})
 	}
 }
 
-func TestSnippetLatest(t *testing.T) {
+func TestSnippetCreatePostFiles(t *testing.T) {
 	app := newTestApplication(t)
 
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
+	param := url.Values{}
+
+	param.Set("title", "title")
+	param.Set("expires", "7")
+	param["file_name"] = []string{"main.go", "go.mod"}
+	param["file_language"] = []string{"go", ""}
+	param["file_content"] = []string{"package main", "module haiku"}
+	payload1 := bytes.NewBufferString(param.Encode())
+
+	param["file_name"] = []string{"main.go", "main.go"}
+	payload2 := bytes.NewBufferString(param.Encode())
+
+	param["file_name"] = []string{"main.go", "../etc/passwd"}
+	payload3 := bytes.NewBufferString(param.Encode())
+
+	param["file_name"] = []string{"main.go"}
+	payload4 := bytes.NewBufferString(param.Encode())
+
+	param["file_name"] = []string{"main.go", "go.mod"}
+	param["file_language"] = []string{"go", "cobol"}
+	payload5 := bytes.NewBufferString(param.Encode())
+
+	param.Del("file_name")
+	param.Del("file_language")
+	param.Del("file_content")
+	payload6 := bytes.NewBufferString(param.Encode())
+
+	param.Set("file_name", "main.go")
+	param.Set("file_language", "")
+	param.Set("file_content", "content")
+	param.Set("add_file", "1")
+	payload7 := bytes.NewBufferString(param.Encode())
+	param.Del("add_file")
+
+	param.Set("remove_file", "0")
+	payload8 := bytes.NewBufferString(param.Encode())
+
+	param.Set("remove_file", "1")
+	payload9 := bytes.NewBufferString(param.Encode())
+
 	tests := []struct {
 		name string
-		path string
+		payload *bytes.Buffer
 		expected int
 	} {
 		{
-			name: "Previous Snippets With ID",
-			path: "/snippets/latest?direction=prev&id=0",
-			expected: http.StatusOK,	
+			name: "Multiple Files",
+			payload: payload1,
+			expected: http.StatusOK,
 		},
 		{
-			name: "Previous Snippets Without ID",
-			path: "/snippets/latest?direction=prev",
+			name: "Duplicate File Names",
+			payload: payload2,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Invalid File Name",
+			payload: payload3,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Mismatched File Fields",
+			payload: payload4,
 			expected: http.StatusBadRequest,
 		},
 		{
-			name: "Next Snippets With ID",
-			path: "/snippets/latest?direction=next&id=2",
+			name: "Unknown Language",
+			payload: payload5,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "No Files",
+			payload: payload6,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Add File Without JavaScript",
+			payload: payload7,
 			expected: http.StatusOK,
 		},
 		{
-			name: "Next Snippets Without ID",
-			path: "/snippets/latest?direction=next",
+			name: "Remove File Without JavaScript",
+			payload: payload8,
+			expected: http.StatusOK,
+		},
+		{
+			name: "Remove Missing File",
+			payload: payload9,
 			expected: http.StatusBadRequest,
 		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, _ := ts.post(t, "/snippet/create", test.payload)
+			assert.Equal(t, code, test.expected)
+		})
+	}
+}
+
+var submitRX = regexp.MustCompile(`<(?:button|input)[^>]*type='submit'[^>]*>`)
+
+// TestSnippetCreateDefaultSubmit checks that pressing Enter in the create
+// form publishes the snippet. Browsers click the first submit button, which
+// must not carry a name, so only the fields of the form are posted.
+func TestSnippetCreateDefaultSubmit(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	first := submitRX.FindString(body)
+	assert.Equal(t, first != "", true)
+	assert.Equal(t, strings.Contains(first, "name="), false)
+
+	param := url.Values{}
+	param.Set("title", "title")
+	param.Set("expires", "7")
+	param["file_name"] = []string{"main.go", "go.mod"}
+	param["file_language"] = []string{"go", ""}
+	param["file_content"] = []string{"package main", "module haiku"}
+
+	code, _, body := ts.post(t, "/snippet/create", bytes.NewBufferString(param.Encode()))
+	assert.Equal(t, code, http.StatusOK)
+
+	// Published, so the snippet is shown rather than the form again.
+	assert.StringContains(t, body, "An old silent pond")
+	assert.Equal(t, strings.Contains(body, "name='file_name'"), false)
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Prefilled Form", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/fork/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<input type='hidden' name='parent' value='1'>")
+		assert.StringContains(t, body, "<input type='text' name='title' value='An old silent pond'>")
+		assert.StringContains(t, body, "<input type='text' name='file_name' value='notes.md' placeholder='e.g. main.go'>")
+		assert.StringContains(t, body, "value='haiku, poetry'")
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/fork/2")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	param := url.Values{}
+
+	param.Set("title", "title")
+	param.Set("file_name", "main.go")
+	param.Set("file_language", "")
+	param.Set("file_content", "content")
+	param.Set("expires", "7")
+	param.Set("parent", "1")
+	payload1 := bytes.NewBufferString(param.Encode())
+
+	param.Set("parent", "0")
+	payload2 := bytes.NewBufferString(param.Encode())
+
+	param.Set("parent", "foo")
+	payload3 := bytes.NewBufferString(param.Encode())
+
+	param.Set("parent", "1")
+	param.Set("title", "")
+	payload4 := bytes.NewBufferString(param.Encode())
+	param.Set("title", "title")
+
+	param.Set("parent", "2")
+	payload5 := bytes.NewBufferString(param.Encode())
+
+	param.Set("parent", "99")
+	payload6 := bytes.NewBufferString(param.Encode())
+
+	tests := []struct {
+		name string
+		payload *bytes.Buffer
+		expected int
+		wantBody string
+	} {
+		{
+			name: "Publish Fork",
+			payload: payload1,
+			expected: http.StatusOK,
+		},
 		{
-			name: "Invalid Direction",
-			path: "/snippets/latest?direction=mid",
+			name: "Zero Parent",
+			payload: payload2,
 			expected: http.StatusBadRequest,
 		},
 		{
-			name: "Just ID",
-			path: "/snippets/latest?id=0",
+			name: "String Parent",
+			payload: payload3,
+			expected: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid Fork Keeps Parent",
+			payload: payload4,
+			expected: http.StatusUnprocessableEntity,
+			wantBody: "<input type='hidden' name='parent' value='1'>",
+		},
+		{
+			name: "Expired Parent",
+			payload: payload5,
+			expected: http.StatusOK,
+		},
+		{
+			name: "Unknown Parent",
+			payload: payload6,
+			expected: http.StatusUnprocessableEntity,
+			wantBody: "Snippet #99 doesn&#39;t exist",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.post(t, "/snippet/create", test.payload)
+			assert.Equal(t, code, test.expected)
+
+			if test.wantBody != "" {
+				assert.StringContains(t, body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetLatest(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	next := app.cursors.encode(&models.Cursor{ID: 2})
+	prev := app.cursors.encode(&models.Cursor{ID: 1, Prev: true})
+	forged := (&cursorCodec{key: []byte("forged")}).encode(&models.Cursor{ID: 2})
+
+	tests := []struct {
+		name string
+		path string
+		expected int
+	} {
+		{
+			name: "Previous Snippets",
+			path: "/snippets/latest?cursor=" + prev,
+			expected: http.StatusOK,	
+		},
+		{
+			name: "Next Snippets",
+			path: "/snippets/latest?cursor=" + next,
+			expected: http.StatusOK,
+		},
+		{
+			name: "Forged Cursor",
+			path: "/snippets/latest?cursor=" + forged,
+			expected: http.StatusBadRequest,
+		},
+		{
+			name: "Malformed Cursor",
+			path: "/snippets/latest?cursor=cursor",
 			expected: http.StatusBadRequest,
 		},
 		{
 			name: "No Query Parameter",
 			path: "/snippets/latest",
+			expected: http.StatusOK,
+		},
+		{
+			name: "Last Page",
+			path: "/snippets/latest?page=last&size=25",
+			expected: http.StatusOK,
+		},
+		{
+			name: "Invalid Page Size",
+			path: "/snippets/latest?size=size",
 			expected: http.StatusBadRequest,
 		},
 	}
@@ -226,6 +727,9 @@ func TestSnippetSearch(t *testing.T) {
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
+	next := app.cursors.encode(&models.Cursor{ID: 2})
+	prev := app.cursors.encode(&models.Cursor{ID: 3, Prev: true})
+
 	tests := []struct {
 		name string
 		path string
@@ -237,13 +741,8 @@ func TestSnippetSearch(t *testing.T) {
 			expected: http.StatusOK,
 		},
 		{
-			name: "Invalid ID",
-			path: "/snippets/search?q=Old&id=id",
-			expected: http.StatusBadRequest,
-		},
-		{
-			name: "Invalid Direction",
-			path: "/snippets/search?q=Old&direction=mid&id=2",
+			name: "Invalid Cursor",
+			path: "/snippets/search?q=Old&cursor=cursor",
 			expected: http.StatusBadRequest,
 		},
 		{
@@ -251,16 +750,31 @@ func TestSnippetSearch(t *testing.T) {
 			path: "/snippets/search?q=q",
 			expected: http.StatusOK,
 		},
+		{
+			name: "Empty Result With Cursor",
+			path: "/snippets/search?q=q&cursor=" + next,
+			expected: http.StatusOK,
+		},
 		{
 			name: "Next Snippets",
-			path: "/snippets/search?q=Old&direction=next&id=2",
+			path: "/snippets/search?q=Old&cursor=" + next,
 			expected: http.StatusOK,
 		},
 		{
 			name: "Prev Snippets",
-			path: "/snippets/search?q=Old&direction=prev&id=0",
+			path: "/snippets/search?q=Old&cursor=" + prev,
 			expected: http.StatusOK,
 		},
+		{
+			name: "Last Page",
+			path: "/snippets/search?q=Old&page=last",
+			expected: http.StatusOK,
+		},
+		{
+			name: "Invalid Page Size",
+			path: "/snippets/search?q=Old&size=100",
+			expected: http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -269,6 +783,93 @@ func TestSnippetSearch(t *testing.T) {
 			assert.Equal(t, code, test.expected)
 		})
 	}
+
+	t.Run("Fragment For Htmx", func(t *testing.T) {
+		r, err := http.NewRequest(http.MethodGet, ts.URL+"/snippets/search?q=Old&cursor="+next, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("HX-Request", "true")
+
+		rs, err := ts.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+		assert.Equal(t, strings.Contains(string(body), "<html"), false)
+		assert.StringContains(t, string(body), "Page 1 of 1")
+	})
+
+	t.Run("Full Page Without Htmx", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippets/search?q=Old&cursor="+next)
+		assert.StringContains(t, body, "<html")
+	})
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		path string
+		wantCode int
+		wantBody string
+	} {
+		{
+			name: "Tag With Snippets",
+			path: "/tags/haiku",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name: "Tag Cloud",
+			path: "/tags/haiku",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tags/poetry' class='tag tag-weight-1' title='1 snippets'>poetry</a>",
+		},
+		{
+			name: "Tag Without Snippets",
+			path: "/tags/bash",
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name: "Paging",
+			path: "/tags/haiku?size=25&page=last",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Invalid Tag",
+			path: "/tags/Sql!",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "Invalid Page Size",
+			path: "/tags/haiku?size=0",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.path)
+			assert.Equal(t, code, test.wantCode)
+
+			if test.wantBody != "" {
+				assert.StringContains(t, body, test.wantBody)
+			}
+		})
+	}
 }
 
 func TestSnippetSeachPost(t *testing.T) {
@@ -316,4 +917,4 @@ func TestSnippetSeachPost(t *testing.T) {
 			assert.Equal(t, code, test.expected)
 		})
 	}
-}
\ No newline at end of file
+}
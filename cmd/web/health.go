@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// probePaths are the endpoints polled by the orchestrator. /healthz and
+// /readyz are served on the main listener, which the orchestrator can reach,
+// as well as the admin one. /version tells which revision is running, so it
+// is only on the admin listener.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// readyCheckTimeout bounds each readiness check so a hung database fails the
+// probe instead of stalling it.
+const readyCheckTimeout = 2 * time.Second
+
+// health holds what the probe endpoints report. ping and pending check the
+// database and its migrations; either may be nil to skip the check.
+type health struct {
+	started      time.Time
+	build        buildInfo
+	shuttingDown atomic.Bool
+	ping         func(context.Context) error
+	pending      func(context.Context) (int, error)
+}
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: "unknown"}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Version = bi.Main.Version
+	info.GoVersion = bi.GoVersion
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+
+	return info
+}
+
+// healthz reports that the process is up and serving requests.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz reports whether the instance should receive traffic, listing the
+// outcome of every check. The probe may be reachable by anyone, so failures
+// are only named there and the errors behind them are logged.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{}
+	ready := true
+
+	fail := func(name, reason string, err error) {
+		checks[name] = reason
+		ready = false
+
+		if err != nil {
+			app.requestLogger(r).Warn("readiness check failed", slog.String("check", name), slog.String("error", err.Error()))
+		}
+	}
+
+	if app.health.shuttingDown.Load() {
+		fail("shutdown", "shutting down", nil)
+	} else {
+		checks["shutdown"] = "ok"
+	}
+
+	if len(app.templateCache) == 0 {
+		fail("templates", "template cache is empty", nil)
+	} else {
+		checks["templates"] = "ok"
+	}
+
+	if app.health.ping != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		err := app.health.ping(ctx)
+		cancel()
+
+		if err != nil {
+			fail("database", "database unavailable", err)
+		} else {
+			checks["database"] = "ok"
+		}
+	}
+
+	if app.health.pending != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		n, err := app.health.pending(ctx)
+		cancel()
+
+		switch {
+		case err != nil:
+			fail("migrations", "migration status unavailable", err)
+		case n > 0:
+			fail("migrations", "migrations pending", nil)
+		default:
+			checks["migrations"] = "ok"
+		}
+	}
+
+	status, text := http.StatusOK, "ok"
+	if !ready {
+		status, text = http.StatusServiceUnavailable, "unavailable"
+	}
+
+	app.writeJSON(w, status, map[string]any{"status": text, "checks": checks})
+}
+
+// version reports which build is running and since when.
+func (app *application) version(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, struct {
+		buildInfo
+		Started string `json:"started"`
+	}{app.health.build, app.health.started.UTC().Format(time.RFC3339)})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	app.health.shuttingDown.Store(true)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "ok\n")
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name         string
+		ping         func(context.Context) error
+		pending      func(context.Context) (int, error)
+		shuttingDown bool
+		wantCode     int
+		wantFailed   string
+		wantReason   string
+		wantLogged   bool
+	}{
+		{
+			name:     "Ready",
+			ping:     func(context.Context) error { return nil },
+			pending:  func(context.Context) (int, error) { return 0, nil },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Database Down",
+			ping:       func(context.Context) error { return errors.New("dial tcp 10.0.0.5:3306: connection refused") },
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: "database",
+			wantReason: "database unavailable",
+			wantLogged: true,
+		},
+		{
+			name:       "Migrations Pending",
+			pending:    func(context.Context) (int, error) { return 2, nil },
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: "migrations",
+			wantReason: "migrations pending",
+		},
+		{
+			name:       "Migration Status Unavailable",
+			pending:    func(context.Context) (int, error) { return 0, errors.New("dial tcp 10.0.0.5:3306: connection refused") },
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: "migrations",
+			wantReason: "migration status unavailable",
+			wantLogged: true,
+		},
+		{
+			name: "Ping Timeout",
+			ping: func(ctx context.Context) error {
+				_, ok := ctx.Deadline()
+				if !ok {
+					t.Error("ping called without a deadline")
+				}
+				return context.DeadlineExceeded
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: "database",
+		},
+		{
+			name:         "Shutting Down",
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+			wantFailed:   "shutdown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+			app.health.ping = tt.ping
+			app.health.pending = tt.pending
+			app.health.shuttingDown.Store(tt.shuttingDown)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, header, body := ts.get(t, "/readyz")
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+			var got struct {
+				Status string
+				Checks map[string]string
+			}
+			err := json.Unmarshal([]byte(body), &got)
+			assert.NilError(t, err)
+
+			for name, result := range got.Checks {
+				if name == tt.wantFailed {
+					assert.Equal(t, result != "ok", true)
+				} else {
+					assert.Equal(t, result, "ok")
+				}
+			}
+
+			if tt.wantReason != "" {
+				assert.Equal(t, got.Checks[tt.wantFailed], tt.wantReason)
+			}
+
+			// Errors are logged rather than shown to whoever asked.
+			assert.Equal(t, strings.Contains(body, "10.0.0.5"), false)
+			assert.Equal(t, strings.Contains(logs.String(), "10.0.0.5"), tt.wantLogged)
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	app := newTestApplication(t)
+
+	// The revision is for operators, so only the admin listener serves it.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/version")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	code, body := rr.Code, rr.Body.String()
+	assert.Equal(t, code, http.StatusOK)
+
+	var got struct {
+		Version   string
+		GoVersion string `json:"go_version"`
+		Started   string
+	}
+	err := json.Unmarshal([]byte(body), &got)
+	assert.NilError(t, err)
+
+	assert.Equal(t, got.Version != "", true)
+	assert.Equal(t, got.GoVersion != "", true)
+	assert.Equal(t, got.Started, app.health.started.UTC().Format("2006-01-02T15:04:05Z07:00"))
+}
+
+func TestAdminProbes(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rr := httptest.NewRecorder()
+		app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, rr.Code, http.StatusOK)
+	}
+}
@@ -2,43 +2,296 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"snippetbox.bimasenaputra/internal/models"
 )
 
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.requestLogger(r).Error(err.Error(), slog.String("trace", string(debug.Stack())))
+
+	app.errorPage(w, r, http.StatusInternalServerError)
+}
+
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorPage(w, r, status)
+}
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+func (app *application) notFoundError(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
 }
 
-func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+// snippetFromParams loads the live snippet named by the :id route parameter.
+// When it returns false the error response has already been written.
+func (app *application) snippetFromParams(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFoundError(w, r)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFoundError(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
 }
 
-func (app *application) notFoundError(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+// snippetETag identifies the rendered view of a snippet. Snippets can't be
+// edited, so the page only changes when forks come and go, the parent expires
+// or a release changes the UI.
+func (app *application) snippetETag(snippet *models.Snippet, forks []*models.Snippet) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%s %d %d\n", app.uiVersion, snippet.ID, snippet.Created.UnixNano())
+
+	if snippet.Parent != nil {
+		fmt.Fprintf(h, "parent %d %t\n", snippet.Parent.ID, snippet.Parent.Live)
+	}
+
+	for _, fork := range forks {
+		fmt.Fprintf(h, "fork %d\n", fork.ID)
+	}
+
+	return fmt.Sprintf(`W/"%d-%x"`, snippet.ID, h.Sum(nil)[:8])
+}
+
+// notModified sets the ETag of the response and reports whether the client
+// already holds that version, in which case 304 Not Modified has been sent.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
 }
 
-func (app *application) render(w http.ResponseWriter, page string, status int, templateData *templateData) {
-	ts, ok := app.templateCache[page]
+func (app *application) render(w http.ResponseWriter, r *http.Request, page string, status int, templateData *templateData) {
+	cache, err := app.templates()
+	if err != nil {
+		app.templateError(w, r, err)
+		return
+	}
+
+	ts, ok := cache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	templateData.CSPNonce = cspNonce(r)
+
 	buf := new(bytes.Buffer)
 
-	err := ts.ExecuteTemplate(buf, "base", templateData)
+	_, span := tracer().Start(r.Context(), "render", trace.WithAttributes(attribute.String("template", page)))
+	start := time.Now()
+	err = ts.ExecuteTemplate(buf, "base", templateData)
+	app.metrics.rendered(page, time.Since(start))
+	span.End()
 	if err != nil {
-		app.serverError(w, err)
+		app.templateError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(status)
 
 	buf.WriteTo(w)
-}
\ No newline at end of file
+}
+
+// pageSizes are the page sizes offered in the UI. Any size up to
+// models.MaxPageSize is accepted in the URL.
+var pageSizes = []int{10, 25, 50}
+
+// snippetFilter reads the paging parameters shared by every snippet listing:
+// an optional signed cursor, page=last to jump to the end and a page size.
+func (app *application) snippetFilter(r *http.Request) (models.SnippetFilter, error) {
+	query := r.URL.Query()
+	filter := models.SnippetFilter{Limit: models.DefaultPageSize}
+
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := app.cursors.decode(token)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = cursor
+	}
+
+	switch query.Get("page") {
+	case "":
+	case "last":
+		filter.Last = true
+	default:
+		return filter, errors.New("invalid page")
+	}
+
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			return filter, fmt.Errorf("page size must be between 1 and %d", models.MaxPageSize)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// snippetPage loads the page of snippets filter asks for. Cursors only stay
+// good while the snippets around them are live, so when a page comes back
+// empty although other snippets match, the listing starts again from the end
+// it was heading towards: the oldest snippets when paging on and the newest
+// when paging back.
+func (app *application) snippetPage(r *http.Request, filter models.SnippetFilter) (*models.SnippetPage, error) {
+	page, err := app.snippets.Page(r.Context(), filter)
+	if err != nil || len(page.Snippets) > 0 || page.Total == 0 {
+		return page, err
+	}
+
+	switch {
+	case filter.Last:
+		filter.Last = false
+	case filter.Cursor != nil:
+		filter.Last = !filter.Cursor.Prev
+	default:
+		return page, nil
+	}
+
+	filter.Cursor = nil
+
+	return app.snippets.Page(r.Context(), filter)
+}
+
+// newPageData prepares a listing page for rendering. params holds the query
+// parameters every link must keep (such as the search query); href and hxGet
+// are the full page and fragment paths the links point at.
+func (app *application) newPageData(page *models.SnippetPage, params url.Values, href, hxGet string) *templateData {
+	if page.Limit != models.DefaultPageSize {
+		params.Set("size", strconv.Itoa(page.Limit))
+	}
+
+	link := func(key, value string) *pageLink {
+		query := url.Values{}
+		for k, v := range params {
+			query[k] = v
+		}
+		if key != "" {
+			query.Set(key, value)
+		}
+
+		encoded := query.Encode()
+		if encoded == "" {
+			return &pageLink{Href: href, HxGet: hxGet}
+		}
+		return &pageLink{Href: href + "?" + encoded, HxGet: hxGet + "?" + encoded}
+	}
+
+	p := &pagination{
+		Number: page.Number(),
+		Pages: page.Pages(),
+		Total: page.Total,
+		Size: page.Limit,
+		Sizes: pageSizes,
+	}
+
+	if page.HasPrev {
+		p.First = link("", "")
+		p.Prev = link("cursor", app.cursors.encode(page.PrevCursor()))
+	}
+
+	if page.HasNext {
+		p.Next = link("cursor", app.cursors.encode(page.NextCursor()))
+		p.Last = link("page", "last")
+	}
+
+	return &templateData{
+		Snippets: page.Snippets,
+		Pagination: p,
+	}
+}
+
+// isHtmx reports whether r was issued by htmx and so expects a fragment.
+// When the back button takes htmx to a page missing from its history cache it
+// fetches the whole page, marking the request with HX-History-Restore-Request.
+func isHtmx(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true"
+}
+
+
+// tagCloudSize is how many of the most used tags the tag cloud shows.
+const tagCloudSize = 30
+
+// tagCloud loads the most used tags and weighs each of them from 1 to 5
+// relative to the least and most used ones.
+func (app *application) tagCloud(r *http.Request) ([]*tagCloudItem, error) {
+	counts, err := app.tags.Cloud(r.Context(), tagCloudSize)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*tagCloudItem{}
+	if len(counts) == 0 {
+		return items, nil
+	}
+
+	min, max := counts[len(counts)-1].Count, counts[0].Count
+
+	for _, c := range counts {
+		weight := 3
+		if max > min {
+			weight = 1 + 4*(c.Count-min)/(max-min)
+		}
+		items = append(items, &tagCloudItem{Name: c.Name, Count: c.Count, Weight: weight})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	return items, nil
+}
+
+// writeJSON sends v as an indented JSON body with the given status.
+func (app *application) writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		app.logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
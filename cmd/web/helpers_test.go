@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/mocks"
+	"snippetbox.bimasenaputra/internal/models"
+)
+
+// liveSnippets is an in-memory snippet store that pages like the database
+// models and whose snippets can be expired at any time, so the listings can
+// be checked against a set of snippets that changes between requests.
+type liveSnippets struct {
+	mocks.SnippetModel
+
+	mu     sync.Mutex
+	nextID int
+	// ids holds the live snippets, newest first.
+	ids []int
+}
+
+func (m *liveSnippets) Insert(ctx context.Context, title string, files []*models.SnippetFile, tags []string, expires int, parentID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	m.ids = append([]int{m.nextID}, m.ids...)
+	return m.nextID, nil
+}
+
+// expire removes the nth live snippet, counting round if there are fewer.
+func (m *liveSnippets) expire(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.ids) == 0 {
+		return
+	}
+
+	i := n % len(m.ids)
+	m.ids = append(m.ids[:i:i], m.ids[i+1:]...)
+}
+
+func (m *liveSnippets) live() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]int{}, m.ids...)
+}
+
+// Page pages with the models' own logic, so only the queries are faked.
+func (m *liveSnippets) Page(ctx context.Context, filter models.SnippetFilter) (*models.SnippetPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	for _, id := range m.ids {
+		snippets = append(snippets, &models.Snippet{
+			ID:      id,
+			Title:   fmt.Sprintf("Snippet %d", id),
+			Created: time.Now(),
+			Expires: time.Now().Add(time.Hour),
+		})
+	}
+
+	return models.PageOf(snippets, filter), nil
+}
+
+var (
+	shownIDRX    = regexp.MustCompile(`<td>#(\d+)</td>`)
+	pagingLinkRX = regexp.MustCompile(`<a href='([^']*)' hx-get='([^']*)'[^>]*>([^<]*)</a>`)
+	pageNumberRX = regexp.MustCompile(`Page (\d+) of (\d+) &middot; (\d+) snippets`)
+)
+
+type shownLink struct {
+	href  string
+	hxGet string
+	label string
+}
+
+// pagingLinks returns the paging links on a rendered listing.
+func pagingLinks(body string) []shownLink {
+	links := []shownLink{}
+	for _, m := range pagingLinkRX.FindAllStringSubmatch(body, -1) {
+		links = append(links, shownLink{href: html.UnescapeString(m[1]), hxGet: html.UnescapeString(m[2]), label: m[3]})
+	}
+	return links
+}
+
+// shownIDs returns the IDs of the snippets listed on a rendered page.
+func shownIDs(body string) []int {
+	ids := []int{}
+	for _, m := range shownIDRX.FindAllStringSubmatch(body, -1) {
+		id, _ := strconv.Atoi(m[1])
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// serveListing requests path from h the way a browser would, or as htmx does
+// when a paging link is clicked.
+func serveListing(h http.Handler, path string, htmx bool) (int, string) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if htmx {
+		r.Header.Set("HX-Request", "true")
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	return rr.Code, rr.Body.String()
+}
+
+// checkListing returns what is wrong with a rendered listing given the
+// snippets that were live when it was served, or "" if nothing is.
+func checkListing(code int, body string, htmx bool, live []int) string {
+	if code != http.StatusOK {
+		return fmt.Sprintf("got status %d", code)
+	}
+
+	if htmx && !strings.Contains(body, "id='response-div'") {
+		return "fragment has no #response-div for the next swap"
+	}
+
+	ids := shownIDs(body)
+
+	if len(live) == 0 {
+		if len(ids) > 0 || !strings.Contains(body, "nothing to see here") {
+			return fmt.Sprintf("listed %v with no live snippets", ids)
+		}
+		return ""
+	}
+
+	if len(ids) == 0 {
+		return fmt.Sprintf("empty page while %d snippets are live", len(live))
+	}
+
+	// Which snippets are listed, and in what order, is up to
+	// models.PageOf and tested with it.
+	m := pageNumberRX.FindStringSubmatch(body)
+	if m == nil {
+		return "no page number"
+	}
+
+	number, _ := strconv.Atoi(m[1])
+	pages, _ := strconv.Atoi(m[2])
+	total, _ := strconv.Atoi(m[3])
+
+	if total != len(live) || number < 1 || number > pages {
+		return fmt.Sprintf("shown as page %d of %d with %d snippets, %d are live", number, pages, total, len(live))
+	}
+
+	return ""
+}
+
+// listingStep is one thing that happens to a listing: a snippet is inserted
+// or expires, or the visitor follows a paging link (N picks which, and
+// whether htmx fetches it), reloads the page or picks a page size.
+type listingStep struct {
+	Action string
+	N      int
+}
+
+type listingScript []listingStep
+
+var listingActions = []string{"insert", "insert", "expire", "click", "click", "reload", "resize"}
+
+func (listingScript) Generate(rand *rand.Rand, size int) reflect.Value {
+	script := make(listingScript, rand.Intn(2*size+1))
+	for i := range script {
+		script[i] = listingStep{Action: listingActions[rand.Intn(len(listingActions))], N: rand.Intn(1000)}
+	}
+	return reflect.ValueOf(script)
+}
+
+func TestSnippetListingProperties(t *testing.T) {
+	property := func(script listingScript) bool {
+		store := &liveSnippets{}
+
+		app := newTestApplication(t)
+		app.snippets = store
+		routes := app.routes()
+
+		location := "/"
+		code, body := serveListing(routes, location, false)
+
+		if problem := checkListing(code, body, false, store.live()); problem != "" {
+			t.Logf("GET %s: %s", location, problem)
+			return false
+		}
+
+		for i, step := range script {
+			path, htmx := location, false
+
+			switch step.Action {
+			case "insert":
+				store.Insert(context.Background(), "", nil, nil, 1, 0)
+				continue
+			case "expire":
+				store.expire(step.N)
+				continue
+			case "resize":
+				path = fmt.Sprintf("/?size=%d", step.N%5+1)
+				location = path
+			case "click":
+				links := pagingLinks(body)
+				if len(links) > 0 {
+					link := links[step.N/2%len(links)]
+					location = link.href
+
+					path, htmx = link.href, step.N%2 == 1
+					if htmx {
+						path = link.hxGet
+					}
+				}
+			}
+
+			code, body = serveListing(routes, path, htmx)
+
+			if problem := checkListing(code, body, htmx, store.live()); problem != "" {
+				t.Logf("step %d %+v, GET %s: %s", i, step, path, problem)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnippetListingEmpty(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &liveSnippets{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	next := app.cursors.encode(&models.Cursor{ID: 2})
+	prev := app.cursors.encode(&models.Cursor{ID: 1, Prev: true})
+
+	for _, path := range []string{
+		"/",
+		"/?page=last",
+		"/?cursor=" + next,
+		"/?cursor=" + prev,
+		"/snippets/latest?page=last",
+		"/snippets/latest?cursor=" + next,
+	} {
+		t.Run(path, func(t *testing.T) {
+			code, _, body := ts.get(t, path)
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, "nothing to see here")
+		})
+	}
+
+	t.Run("Fragment Keeps Swap Target", func(t *testing.T) {
+		code, body := serveListing(app.routes(), "/snippets/latest?cursor="+next, true)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<div id='response-div'>")
+	})
+}
+
+func TestSnippetListingExpiredCursor(t *testing.T) {
+	store := &liveSnippets{}
+	for range 6 {
+		store.Insert(context.Background(), "", nil, nil, 1, 0)
+	}
+
+	app := newTestApplication(t)
+	app.snippets = store
+	routes := app.routes()
+
+	older := app.cursors.encode(&models.Cursor{ID: 3})
+	newer := app.cursors.encode(&models.Cursor{ID: 4, Prev: true})
+
+	// Everything either side of snippets 3 and 4 expires before the
+	// visitor follows a link.
+	store.expire(0)
+	store.expire(0)
+	store.expire(2)
+	store.expire(2)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantIDs  []int
+		wantPage string
+	}{
+		{
+			name:     "Next Page Expired",
+			path:     "/snippets/latest?size=1&cursor=" + older,
+			wantIDs:  []int{3},
+			wantPage: "Page 2 of 2",
+		},
+		{
+			name:     "Previous Page Expired",
+			path:     "/snippets/latest?size=1&cursor=" + newer,
+			wantIDs:  []int{4},
+			wantPage: "Page 1 of 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveListing(routes, tt.path, true)
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, slices.Equal(shownIDs(body), tt.wantIDs), true)
+			assert.StringContains(t, body, tt.wantPage)
+		})
+	}
+}
+
+func TestHistoryRestore(t *testing.T) {
+	app := newTestApplication(t)
+	routes := app.routes()
+
+	for _, path := range []string{"/snippets/search?q=Old", "/tags/haiku"} {
+		t.Run(path, func(t *testing.T) {
+			code, body := serveListing(routes, path, true)
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, strings.Contains(body, "<!doctype html>"), false)
+
+			// Going back to a page htmx didn't cache needs the whole page.
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Header.Set("HX-Request", "true")
+			r.Header.Set("HX-History-Restore-Request", "true")
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.StringContains(t, rr.Body.String(), "<!doctype html>")
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger, err := newLogger(buf, "text", slog.LevelWarn)
+	assert.NilError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", slog.Int("count", 2))
+	assert.StringContains(t, buf.String(), `level=WARN msg=shown count=2`)
+	assert.Equal(t, bytes.Contains(buf.Bytes(), []byte("hidden")), false)
+
+	_, err = newLogger(buf, "xml", slog.LevelInfo)
+	assert.Equal(t, err != nil, true)
+}
@@ -2,87 +2,301 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
-	"net/http"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"snippetbox.bimasenaputra/internal/migrations"
 	"snippetbox.bimasenaputra/internal/models"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+	"snippetbox.bimasenaputra/ui"
 )
 
 type application struct {
-	errorLog *log.Logger
-	infoLog *log.Logger
+	logger *slog.Logger
 	snippets models.SnippetModelInterface
+	tags models.TagModelInterface
 	templateCache map[string]*template.Template
+	cursors *cursorCodec
+	rateLimits rateLimitConfig
+	rateStore ratelimit.Store
+	accessLog *accessLog
+	metrics *appMetrics
+	health *health
+	// dev reloads templates from disk and shows template errors in the
+	// browser, reloader doing the reloading.
+	dev bool
+	reloader *templateReloader
+	static *staticAssets
+	// uiVersion changes whenever the templates or static files do.
+	uiVersion string
+	security securityPolicy
 }
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP Network Address")
-	dsn := flag.String("dsn", "web:password@/snippetbox?parseTime=true", "MySQL Data Source Name")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[2:], os.LookupEnv, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	started := time.Now()
 
-	flag.Parse()
+	cfg, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.PrintConfig {
+		cfg.print(os.Stdout)
+		return
+	}
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	logger, err := newLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	db, err := openDB(*dsn)
+	err = run(cfg, logger, started)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until it has shut down. Everything it
+// opens is closed on return, whether or not it fails.
+func run(cfg *config, logger *slog.Logger, started time.Time) error {
+	shutdownTracing, err := setupTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("flushing traces", slog.String("error", err.Error()))
+		}
+	}()
+
+	db, err := openDB(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return err
 	}
 
 	defer db.Close()
 
-	templateCache, err := newTemplateCache()
+	cfg.DB.apply(db)
+
+	migrator, err := migrations.New(db, cfg.Driver)
+	if err != nil {
+		return err
+	}
+
+	if cfg.AutoMigrate {
+		n, err := migrator.Up(context.Background())
+		if err != nil {
+			return err
+		}
+
+		logger.Info("applied migrations", slog.Int("count", n))
+	}
+
+	var uiFiles fs.FS = ui.Files
+	if cfg.UIDir != "" {
+		uiFiles = os.DirFS(cfg.UIDir)
+	}
+
+	staticFiles, err := fs.Sub(uiFiles, "static")
+	if err != nil {
+		return err
+	}
+
+	assets, err := newStaticAssets(staticFiles)
+	if err != nil {
+		return err
+	}
+
+	version, err := uiDigest(uiFiles)
+	if err != nil {
+		return err
+	}
+
+	var reloader *templateReloader
+	if cfg.Dev {
+		assets = newLiveStaticAssets(staticFiles)
+		reloader = &templateReloader{fsys: uiFiles, assets: assets}
+
+		logger.Warn("development mode: templates are reloaded from disk and caching is off", slog.String("ui_dir", cfg.UIDir))
+	}
+
+	templateCache, err := newTemplateCache(uiFiles, assets)
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
+	}
+
+	// Only -dev runs without a cursor secret, which loadConfig checks.
+	cursorKey := []byte(cfg.CursorSecret)
+	if len(cursorKey) == 0 {
+		cursorKey = make([]byte, 32)
+		_, err = rand.Read(cursorKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	var access *accessLog
+
+	if cfg.AccessLogPath != "" {
+		var out io.Writer = os.Stdout
+
+		if cfg.AccessLogPath != "-" {
+			file, err := openRotatingFile(cfg.AccessLogPath, cfg.AccessLogMaxSize<<20, cfg.AccessLogBackups)
+			if err != nil {
+				return err
+			}
+
+			defer file.Close()
+			out = file
+		}
+
+		access, err = newAccessLog(out, cfg.AccessLogFormat)
+		if err != nil {
+			return err
+		}
 	}
 
 	app := &application {
-		errorLog: errorLog,
-		infoLog: infoLog,
-		snippets: &models.SnippetModel{DB: db},
+		logger: logger,
 		templateCache: templateCache,
+		static: assets,
+		dev: cfg.Dev,
+		reloader: reloader,
+		uiVersion: version,
+		security: cfg.Security,
+		cursors: &cursorCodec{key: cursorKey},
+		rateLimits: cfg.Limits,
+		accessLog: access,
+		health: &health{
+			started: started,
+			build: readBuildInfo(),
+			ping: db.PingContext,
+			pending: migrator.Pending,
+		},
 	}
 
-	infoLog.Println("Starting server on", *addr)
+	err = app.useDatabase(cfg.Driver, db)
+	if err != nil {
+		return err
+	}
 
-	server := &http.Server{
-		Addr: *addr,
-		ErrorLog: errorLog,
-		Handler: app.routes(),
-		IdleTimeout: time.Minute,
-		ReadTimeout: 5 * time.Minute,
-		WriteTimeout: 10 * time.Minute,
+	rateStore, sweepRateStore, err := app.newRateStore(cfg.LimitStore, cfg.Driver, db)
+	if err != nil {
+		return err
 	}
 
-	shutdownErr := make(chan error)
+	app.rateStore = rateStore
 
-	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		s := <-quit
+	listeners := []listener{}
+	workers := []func(ctx context.Context){}
 
-		errorLog.Println(s)
-		
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer cancel()
+	if sweepRateStore != nil {
+		workers = append(workers, sweepRateStore)
+	}
 
-		shutdownErr <- server.Shutdown(ctx)
-	}()
+	if cfg.AdminAddr != "" {
+		app.metrics = app.newMetrics(db)
+
+		admin := newServer(cfg.AdminAddr, app.adminRoutes(), cfg.Timeouts, logger)
+		listeners = append(listeners, listener{name: "admin server", server: admin})
+	}
+
+	if cfg.TLSSelfSigned {
+		generated, err := ensureSelfSigned(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+
+		if generated {
+			logger.Warn("generated a self-signed certificate", slog.String("cert", cfg.TLSCert), slog.String("key", cfg.TLSKey))
+		}
+	}
+
+	server := newServer(cfg.Addr, app.routes(), cfg.Timeouts, logger)
+
+	if cfg.TLSCert != "" {
+		certs, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+
+		server.TLSConfig = newTLSConfig(certs)
+
+		workers = append(workers, func(ctx context.Context) {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-hup:
+				}
+
+				err := certs.reload()
+				if err != nil {
+					logger.Error("reloading TLS certificate", slog.String("error", err.Error()))
+					continue
+				}
+
+				logger.Info("reloaded TLS certificate", slog.String("cert", cfg.TLSCert))
+			}
+		})
+	}
+
+	listeners = append(listeners, listener{name: "server", server: server, tls: server.TLSConfig != nil})
 
-	err = server.ListenAndServe()
-	errorLog.Fatal(err)
+	if cfg.TLSRedirectAddr != "" {
+		handler, err := redirectToHTTPS(cfg.Addr)
+		if err != nil {
+			return err
+		}
+
+		redirect := newServer(cfg.TLSRedirectAddr, handler, cfg.Timeouts, logger)
+		listeners = append(listeners, listener{name: "HTTPS redirect server", server: redirect})
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	return app.serve(listeners, workers, stop, cfg.Shutdown)
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+func openDB(driver, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -90,4 +304,20 @@ func openDB(dsn string) (*sql.DB, error) {
 		return nil, err
 	}
 	return db, err
-}
\ No newline at end of file
+}
+
+// useDatabase wires the models for the given driver into app.
+func (app *application) useDatabase(driver string, db *sql.DB) error {
+	switch driver {
+	case "mysql":
+		app.snippets = models.TraceSnippets(&models.SnippetModel{DB: db}, driver)
+		app.tags = models.TraceTags(&models.TagModel{DB: db}, driver)
+	case "postgres":
+		app.snippets = models.TraceSnippets(&models.PostgresSnippetModel{DB: db}, "postgresql")
+		app.tags = models.TraceTags(&models.PostgresTagModel{DB: db}, "postgresql")
+	default:
+		return fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	return nil
+}
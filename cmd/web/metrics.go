@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// appMetrics are the metrics served on the admin listener. A nil *appMetrics
+// records nothing, which is what tests and instances without an admin
+// listener use.
+type appMetrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	rateLimited     *prometheus.CounterVec
+	panics          prometheus.Counter
+	renderDuration  *prometheus.HistogramVec
+}
+
+func (app *application) newMetrics(db *sql.DB) *appMetrics {
+	m := &appMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "snippetbox_http_request_duration_seconds",
+			Help: "Time taken to serve HTTP requests, by route pattern and method.",
+		}, []string{"route", "method"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_rate_limit_rejections_total",
+			Help: "Requests rejected by the rate limiter, by request class.",
+		}, []string{"class"}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_panics_total",
+			Help: "Panics recovered while serving requests.",
+		}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "snippetbox_template_render_duration_seconds",
+			Help: "Time taken to execute page templates, by template.",
+		}, []string{"template"}),
+	}
+
+	m.registry.MustRegister(m.requests, m.requestDuration, m.rateLimited, m.panics, m.renderDuration)
+
+	stat := func(fn func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return fn(db.Stats()) }
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "snippetbox_db_open_connections", Help: "Open database connections."},
+			stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "snippetbox_db_in_use_connections", Help: "Database connections in use."},
+			stat(func(s sql.DBStats) float64 { return float64(s.InUse) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "snippetbox_db_idle_connections", Help: "Idle database connections."},
+			stat(func(s sql.DBStats) float64 { return float64(s.Idle) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "snippetbox_db_max_open_connections", Help: "Maximum open database connections."},
+			stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "snippetbox_db_wait_count_total", Help: "Times a caller waited for a database connection."},
+			stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "snippetbox_db_wait_duration_seconds_total", Help: "Time spent waiting for database connections."},
+			stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })),
+		&snippetCollector{app: app, timeout: snippetCountTimeout},
+	)
+
+	return m
+}
+
+var snippetsDesc = prometheus.NewDesc("snippetbox_snippets", "Stored snippets, by state (live or expired).", []string{"state"}, nil)
+
+// snippetCountTimeout bounds the query behind snippetbox_snippets, so that a
+// slow database doesn't hang every scrape.
+const snippetCountTimeout = 3 * time.Second
+
+// snippetCollector counts the stored snippets when scraped, as keeping the
+// counts current would mean a query on every insert and expiry.
+type snippetCollector struct {
+	app     *application
+	timeout time.Duration
+}
+
+func (c *snippetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- snippetsDesc
+}
+
+func (c *snippetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	live, expired, err := c.app.snippets.Counts(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(snippetsDesc, fmt.Errorf("counting snippets: %w", err))
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(snippetsDesc, prometheus.GaugeValue, float64(live), "live")
+	ch <- prometheus.MustNewConstMetric(snippetsDesc, prometheus.GaugeValue, float64(expired), "expired")
+}
+
+func (m *appMetrics) rateLimitRejected(class string) {
+	if m != nil {
+		m.rateLimited.WithLabelValues(class).Inc()
+	}
+}
+
+func (m *appMetrics) panicked() {
+	if m != nil {
+		m.panics.Inc()
+	}
+}
+
+func (m *appMetrics) rendered(page string, d time.Duration) {
+	if m != nil {
+		m.renderDuration.WithLabelValues(page).Observe(d.Seconds())
+	}
+}
+
+// knownMethods are the methods that get a label of their own. The method is
+// chosen by the client, so any other is counted as "OTHER" rather than
+// starting a new series.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return "OTHER"
+}
+
+const routeContextKey = contextKey("route")
+
+// withRoute makes sure r carries somewhere for recordRoute to store the
+// matched pattern and returns it. Requests that match no route keep
+// "unmatched".
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+		return r, route
+	}
+
+	route := "unmatched"
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey, &route)), &route
+}
+
+// recordRoute wraps the handler registered for pattern so that instrument can
+// label metrics with the pattern rather than the raw path, which would give
+// every snippet its own series.
+func recordRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+			*route = pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// instrument counts and times every request by the route it matched.
+// Requests that matched no route, or never reached the router because the
+// rate limiter turned them away, are labelled "unmatched".
+func (app *application) instrument(next http.Handler) http.Handler {
+	if app.metrics == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		r, route := withRoute(r)
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		method := methodLabel(r.Method)
+
+		app.metrics.requests.WithLabelValues(*route, method, strconv.Itoa(sw.status)).Inc()
+		app.metrics.requestDuration.WithLabelValues(*route, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// adminRoutes serves the endpoints meant for operators only, on their own
+// listener.
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	if app.metrics != nil {
+		// A failed collector is logged and left out rather than failing the
+		// whole scrape.
+		mux.Handle("GET /metrics", promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{
+			ErrorLog:      slog.NewLogLogger(app.logger.Handler(), slog.LevelWarn),
+			ErrorHandling: promhttp.ContinueOnError,
+		}))
+	}
+
+	mux.HandleFunc("GET /healthz", app.healthz)
+	mux.HandleFunc("GET /readyz", app.readyz)
+	mux.HandleFunc("GET /version", app.version)
+
+	return mux
+}
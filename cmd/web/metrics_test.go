@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/mocks"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = rateLimitConfig{
+		Write: ratelimit.Budget{Rate: 0.001, Burst: 1},
+		Idle: time.Minute,
+	}
+
+	// Opening doesn't connect, which is all the pool stats need.
+	db, err := sql.Open("mysql", "web:pass@/snippetbox")
+	assert.NilError(t, err)
+	defer db.Close()
+
+	app.metrics = app.newMetrics(db)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/snippet/view/2")
+	ts.get(t, "/missing/page")
+	ts.post(t, "/snippet/create", new(bytes.Buffer))
+	ts.post(t, "/snippet/create", new(bytes.Buffer))
+
+	// Methods are picked by the client, so unknown ones share a label.
+	for _, method := range []string{"BREW", "WHEN"} {
+		req, err := http.NewRequest(method, ts.URL+"/", nil)
+		assert.NilError(t, err)
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+	}
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	body := rr.Body.String()
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="200"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="OTHER",route="unmatched",status="429"} 2`)
+	assert.StringContains(t, body, `snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/view/:id"} 2`)
+	assert.Equal(t, strings.Contains(body, "BREW"), false)
+	assert.StringContains(t, body, `snippetbox_rate_limit_rejections_total{class="write"} 3`)
+	assert.StringContains(t, body, `snippetbox_template_render_duration_seconds_count{template="view.html"} 1`)
+	assert.StringContains(t, body, `snippetbox_snippets{state="live"} 3`)
+	assert.StringContains(t, body, `snippetbox_snippets{state="expired"} 1`)
+	assert.StringContains(t, body, "snippetbox_db_open_connections 0")
+	assert.StringContains(t, body, "snippetbox_panics_total")
+}
+
+// slowCounts stands in for a database that doesn't answer.
+type slowCounts struct {
+	mocks.SnippetModel
+}
+
+func (m *slowCounts) Counts(ctx context.Context) (int, int, error) {
+	<-ctx.Done()
+	return 0, 0, ctx.Err()
+}
+
+func TestMetricsSlowSnippetCount(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &slowCounts{}
+
+	db, err := sql.Open("mysql", "web:pass@/snippetbox")
+	assert.NilError(t, err)
+	defer db.Close()
+
+	app.metrics = app.newMetrics(db)
+
+	// Swap in a collector that gives up sooner than the real one.
+	app.metrics.registry.Unregister(&snippetCollector{app: app})
+	app.metrics.registry.MustRegister(&snippetCollector{app: app, timeout: 10 * time.Millisecond})
+
+	ts := newTestServer(t, app.adminRoutes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/metrics")
+
+	// The rest of the metrics are still served.
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "snippetbox_db_open_connections 0")
+	assert.Equal(t, strings.Contains(body, "snippetbox_snippets{"), false)
+}
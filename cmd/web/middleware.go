@@ -2,25 +2,76 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"golang.org/x/time/rate"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"snippetbox.bimasenaputra/internal/ratelimit"
 )
 
-func secureHeaders(next http.Handler) http.Handler {
+// secureHeaders sets the security headers of every response, following
+// app.security. The Content-Security-Policy allows the scripts carrying the
+// nonce made for this request.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; script-src-elem 'self' unpkg.com")
-		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "deny")
-		w.Header().Set("X-XSS-Protection", "0")
-		next.ServeHTTP(w, r)
+		nonce := newCSPNonce()
+
+		app.security.apply(w.Header(), nonce, isSecure(r, app.rateLimits.TrustedProxies))
+
+		next.ServeHTTP(w, withCSPNonce(r, nonce))
 	})
 }
 
+// logRequest logs every request once it has been served, and writes it to the
+// access log if there is one. Each request gets an ID, taken from the
+// X-Request-ID header when a proxy already assigned one, which is sent back in
+// the response and shared by every record logged through requestLogger.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
-		next.ServeHTTP(w, r)
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		logger := app.logger.With(
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		)
+
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, withLogger(r, logger))
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		remoteIP := clientIP(r, app.rateLimits.TrustedProxies)
+
+		logger.Info("request",
+			slog.String("remote_ip", remoteIP),
+			slog.String("proto", r.Proto),
+			slog.Int("status", sw.status),
+			slog.Int("bytes", sw.bytes),
+			slog.Duration("latency", time.Since(start)),
+		)
+
+		if app.accessLog != nil {
+			app.accessLog.log(r, remoteIP, sw.status, sw.bytes, start)
+		}
 	})
 }
 
@@ -30,7 +81,8 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		defer func () {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.metrics.panicked()
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -38,13 +90,50 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// rateLimiter charges every request to its client's bucket for the request
+// class and rejects it once the bucket is empty. The RateLimit-* headers follow
+// the IETF draft and carry whole seconds.
 func (app *application) rateLimiter(next http.Handler) http.Handler {
-	limiter := rate.NewLimiter(10, 20)
+	limits := app.rateLimits
+
+	store := app.rateStore
+	if store == nil {
+		store = ratelimit.NewMemoryStore(limits.Idle)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !limiter.Allow() {
-			app.clientError(w, http.StatusTooManyRequests)
+		class := rateClass(r)
+
+		budget := limits.budget(class)
+		if budget.Burst == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, span := tracer().Start(r.Context(), "rateLimiter", trace.WithAttributes(attribute.String("ratelimit.class", class)))
+		d, err := store.Take(ctx, rateKey(r, class, limits.TrustedProxies), budget)
+		span.SetAttributes(attribute.Bool("ratelimit.allowed", d.Allowed))
+		span.End()
+		if err != nil {
+			app.serverError(w, r, err)
 			return
 		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("RateLimit-Reset", wholeSeconds(d.Reset))
+
+		if !d.Allowed {
+			w.Header().Set("Retry-After", wholeSeconds(d.RetryAfter))
+			app.metrics.rateLimitRejected(class)
+			app.clientError(w, r, http.StatusTooManyRequests)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+
+func wholeSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -2,53 +2,493 @@ package main
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/ratelimit"
 )
 
 func TestSecureHeaders(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
 
-	rr := httptest.NewRecorder()
-	r, err := http.NewRequest(http.MethodGet, "/", nil)
-	if err != nil {
-		t.Fatal(err)
+	const csp = "default-src 'self'; script-src 'self' 'nonce-NONCE'; style-src 'self'; font-src 'self'; img-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors %s; report-uri /csp-report; report-to csp-endpoint"
+
+	defaultHeaders := map[string]string{
+		"Content-Security-Policy": fmt.Sprintf(csp, "'none'"),
+		"Reporting-Endpoints": `csp-endpoint="/csp-report"`,
+		"X-Frame-Options": "DENY",
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy": "strict-origin-when-cross-origin",
+		"Permissions-Policy": "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		"Cross-Origin-Opener-Policy": "same-origin",
+		"Cross-Origin-Resource-Policy": "same-origin",
+	}
+
+	// with returns the default headers changed by changes, where "" removes
+	// a header.
+	with := func(changes map[string]string) map[string]string {
+		headers := map[string]string{}
+		for k, v := range defaultHeaders {
+			headers[k] = v
+		}
+		for k, v := range changes {
+			if v == "" {
+				delete(headers, k)
+			} else {
+				headers[k] = v
+			}
+		}
+		return headers
+	}
+
+	tests := []struct {
+		name string
+		policy func(p *securityPolicy)
+		tls bool
+		remoteAddr string
+		forwardedProto string
+		want map[string]string
+	} {
+		{
+			name: "Defaults",
+			want: defaultHeaders,
+		},
+		{
+			name: "HTTPS",
+			tls: true,
+			want: with(map[string]string{"Strict-Transport-Security": "max-age=31536000"}),
+		},
+		{
+			name: "HTTPS Through Trusted Proxy",
+			remoteAddr: "10.1.2.3:1234",
+			forwardedProto: "https",
+			want: with(map[string]string{"Strict-Transport-Security": "max-age=31536000"}),
+		},
+		{
+			name: "Forwarded Proto From Client",
+			remoteAddr: "192.0.2.1:1234",
+			forwardedProto: "https",
+			want: defaultHeaders,
+		},
+		{
+			name: "HSTS Preload",
+			policy: func(p *securityPolicy) {
+				p.HSTS = hstsPolicy{MaxAge: 2 * 365 * 24 * time.Hour, IncludeSubdomains: true, Preload: true}
+			},
+			tls: true,
+			want: with(map[string]string{"Strict-Transport-Security": "max-age=63072000; includeSubDomains; preload"}),
+		},
+		{
+			name: "HSTS Off",
+			policy: func(p *securityPolicy) { p.HSTS.MaxAge = 0 },
+			tls: true,
+			want: defaultHeaders,
+		},
+		{
+			name: "Framed By Same Origin",
+			policy: func(p *securityPolicy) { p.FrameAncestors = "'self'" },
+			want: with(map[string]string{
+				"Content-Security-Policy": fmt.Sprintf(csp, "'self'"),
+				"X-Frame-Options": "SAMEORIGIN",
+			}),
+		},
+		{
+			name: "Framed By Other Sites",
+			policy: func(p *securityPolicy) { p.FrameAncestors = "https://example.com" },
+			want: with(map[string]string{
+				"Content-Security-Policy": fmt.Sprintf(csp, "https://example.com"),
+				"X-Frame-Options": "",
+			}),
+		},
+		{
+			name: "Report Only",
+			policy: func(p *securityPolicy) { p.CSPReportOnly = true },
+			want: with(map[string]string{
+				"Content-Security-Policy": "",
+				"Content-Security-Policy-Report-Only": fmt.Sprintf(csp, "'none'"),
+			}),
+		},
+		{
+			name: "Headers Left Out",
+			policy: func(p *securityPolicy) {
+				p.ReferrerPolicy = ""
+				p.PermissionsPolicy = ""
+				p.OpenerPolicy = ""
+				p.ResourcePolicy = ""
+			},
+			want: with(map[string]string{
+				"Referrer-Policy": "",
+				"Permissions-Policy": "",
+				"Cross-Origin-Opener-Policy": "",
+				"Cross-Origin-Resource-Policy": "",
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.rateLimits.TrustedProxies = []*net.IPNet{trusted}
+			if tt.policy != nil {
+				tt.policy(&app.security)
+			}
+
+			var nonce string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce = cspNonce(r)
+				w.Write([]byte("OK"))
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.remoteAddr != "" {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwardedProto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			rr := httptest.NewRecorder()
+			app.secureHeaders(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, len(nonce) >= 22, true)
+			assert.Equal(t, rr.Body.String(), "OK")
+
+			got := map[string]string{}
+			for name := range rr.Header() {
+				if name != "Content-Type" {
+					got[name] = strings.ReplaceAll(rr.Header().Get(name), nonce, "NONCE")
+				}
+			}
+
+			assert.Equal(t, len(got), len(tt.want))
+			for name, value := range tt.want {
+				assert.Equal(t, got[name], value)
+			}
+		})
+	}
+}
+
+func TestRouteSecurity(t *testing.T) {
+	app := newTestApplication(t)
+	app.security.EmbedRoutes = "/snippet/view/:id"
+	app.security.EmbedAncestors = "https://blog.example"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		path string
+		wantAncestors string
+		wantFrameOptions string
+	} {
+		{
+			name: "Embeddable Route",
+			path: "/snippet/view/1",
+			wantAncestors: "frame-ancestors https://blog.example;",
+			wantFrameOptions: "",
+		},
+		{
+			name: "Embeddable Route Not Found",
+			path: "/snippet/view/99",
+			wantAncestors: "frame-ancestors https://blog.example;",
+			wantFrameOptions: "",
+		},
+		{
+			name: "Other Route",
+			path: "/",
+			wantAncestors: "frame-ancestors 'none';",
+			wantFrameOptions: "DENY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, header, _ := ts.get(t, tt.path)
+
+			assert.StringContains(t, header.Get("Content-Security-Policy"), tt.wantAncestors)
+			assert.Equal(t, header.Get("X-Frame-Options"), tt.wantFrameOptions)
+			assert.Equal(t, header.Get("X-XSS-Protection"), "")
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	app := &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		rateLimits: rateLimitConfig{
+			Read: ratelimit.Budget{Rate: 1, Burst: 2},
+			Write: ratelimit.Budget{Rate: 1, Burst: 1},
+			Idle: time.Minute,
+		},
 	}
 
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("OK"))
+		w.Write([]byte("OK"))
 	})
 
-	secureHeaders(next).ServeHTTP(rr, r)
+	handler := app.rateLimiter(next)
+
+	serve := func(method, path, remoteAddr string) *http.Response {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(rr, r)
+		return rr.Result()
+	}
+
+	rs := serve(http.MethodGet, "/", "192.0.2.1:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("RateLimit-Limit"), "2")
+	assert.Equal(t, rs.Header.Get("RateLimit-Remaining"), "1")
+	assert.Equal(t, rs.Header.Get("RateLimit-Reset"), "1")
+
+	rs = serve(http.MethodGet, "/", "192.0.2.1:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("RateLimit-Remaining"), "0")
 
-	rs := rr.Result()
+	rs = serve(http.MethodGet, "/", "192.0.2.1:5678")
+	assert.Equal(t, rs.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, rs.Header.Get("Retry-After"), "1")
+	assert.Equal(t, rs.Header.Get("RateLimit-Reset"), "2")
 
-	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; script-src-elem 'self' unpkg.com"
-	assert.Equal(t, rs.Header.Get("Content-Security-Policy"), expectedValue)
+	// Other clients and other budgets are unaffected.
+	rs = serve(http.MethodGet, "/", "192.0.2.2:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
 
-	expectedValue = "origin-when-cross-origin"
-	assert.Equal(t, rs.Header.Get("Referrer-Policy"), expectedValue)
-	
-	expectedValue = "nosniff"
-	assert.Equal(t, rs.Header.Get("X-Content-Type-Options"), expectedValue)
+	rs = serve(http.MethodPost, "/snippet/create", "192.0.2.1:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("RateLimit-Limit"), "1")
 
-	expectedValue = "deny"
-	assert.Equal(t, rs.Header.Get("X-Frame-Options"), expectedValue)
+	rs = serve(http.MethodPost, "/snippet/create", "192.0.2.1:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusTooManyRequests)
 
-	expectedValue = "0"
-	assert.Equal(t, rs.Header.Get("X-XSS-Protection"), expectedValue)
+	// The search budget is disabled.
+	rs = serve(http.MethodGet, "/snippets/search?q=pond", "192.0.2.1:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("RateLimit-Limit"), "")
 
+	// Authenticated clients have buckets of their own, wherever they come
+	// from.
+	serveAs := func(identity, remoteAddr string) *http.Response {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/snippet/create", nil)
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(rr, withRateIdentity(r, identity))
+		return rr.Result()
+	}
+
+	rs = serveAs("alice", "192.0.2.1:1234")
 	assert.Equal(t, rs.StatusCode, http.StatusOK)
+
+	rs = serveAs("alice", "192.0.2.3:1234")
+	assert.Equal(t, rs.StatusCode, http.StatusTooManyRequests)
+
+	rs = serveAs("bob", "192.0.2.3:1234")
 	assert.Equal(t, rs.StatusCode, http.StatusOK)
-	defer rs.Body.Close()
-	
-	body, err := io.ReadAll(rs.Body)
-	if err != nil {
-		t.Fatal(err)
-	}
-	
-	bytes.TrimSpace(body)
-	assert.Equal(t, string(body), "OK")
-}
\ No newline at end of file
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseNetworks("10.0.0.0/8, 192.0.2.10")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name string
+		remoteAddr string
+		forwardedFor []string
+		want string
+	} {
+		{
+			name: "Direct",
+			remoteAddr: "198.51.100.7:1234",
+			want: "198.51.100.7",
+		},
+		{
+			name: "Untrusted Proxy",
+			remoteAddr: "198.51.100.7:1234",
+			forwardedFor: []string{"203.0.113.5"},
+			want: "198.51.100.7",
+		},
+		{
+			name: "Trusted Proxy",
+			remoteAddr: "10.1.2.3:1234",
+			forwardedFor: []string{"203.0.113.5"},
+			want: "203.0.113.5",
+		},
+		{
+			name: "Proxy Chain",
+			remoteAddr: "192.0.2.10:1234",
+			forwardedFor: []string{"203.0.113.5, 10.0.0.1", "10.0.0.2"},
+			want: "203.0.113.5",
+		},
+		{
+			name: "Spoofed Header",
+			remoteAddr: "10.1.2.3:1234",
+			forwardedFor: []string{"1.1.1.1, 203.0.113.5"},
+			want: "203.0.113.5",
+		},
+		{
+			name: "Malformed Header",
+			remoteAddr: "10.1.2.3:1234",
+			forwardedFor: []string{"bogus"},
+			want: "10.1.2.3",
+		},
+		{
+			name: "IPv6",
+			remoteAddr: "[2001:db8::1]:1234",
+			want: "2001:db8::1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, v := range test.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, clientIP(r, trusted), test.want)
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger, err := newLogger(buf, "json", slog.LevelInfo)
+	assert.NilError(t, err)
+
+	app := newTestApplication(t)
+	app.logger = logger
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			app.serverError(w, r, errors.New("boom"))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("Created"))
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/snippet/create", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	app.logRequest(next).ServeHTTP(rr, r)
+
+	var record logRecord
+
+	err = json.Unmarshal(buf.Bytes(), &record)
+	assert.NilError(t, err)
+	assert.Equal(t, record.Level, "INFO")
+	assert.Equal(t, record.Msg, "request")
+	assert.Equal(t, record.Method, "POST")
+	assert.Equal(t, record.Path, "/snippet/create")
+	assert.Equal(t, record.RemoteIP, "192.0.2.1")
+	assert.Equal(t, record.Status, http.StatusCreated)
+	assert.Equal(t, record.Bytes, len("Created"))
+	assert.Equal(t, record.RequestID != "", true)
+	assert.Equal(t, record.Latency > 0, true)
+
+	// Errors are logged with the attributes of their request and the stack
+	// trace as a single field.
+	buf.Reset()
+
+	rr = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/fail", nil)
+
+	app.logRequest(next).ServeHTTP(rr, r)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Equal(t, len(lines), 2)
+
+	var failure, request logRecord
+
+	assert.NilError(t, json.Unmarshal(lines[0], &failure))
+	assert.NilError(t, json.Unmarshal(lines[1], &request))
+	assert.Equal(t, failure.Level, "ERROR")
+	assert.Equal(t, failure.Msg, "boom")
+	assert.Equal(t, failure.Path, "/fail")
+	assert.Equal(t, failure.RequestID, request.RequestID)
+	assert.StringContains(t, failure.Trace, "goroutine")
+	assert.Equal(t, request.Status, http.StatusInternalServerError)
+}
+
+// logRecord holds the fields of a JSON log line the tests look at.
+type logRecord struct {
+	Level string `json:"level"`
+	Msg string `json:"msg"`
+	RequestID string `json:"request_id"`
+	Method string `json:"method"`
+	Path string `json:"path"`
+	RemoteIP string `json:"remote_ip"`
+	Status int `json:"status"`
+	Bytes int `json:"bytes"`
+	Latency time.Duration `json:"latency"`
+	Trace string `json:"trace"`
+}
+
+func TestRequestID(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.notFoundError(w, r)
+	})
+
+	tests := []struct {
+		name string
+		header string
+		wantGenerated bool
+	} {
+		{
+			name: "Generated",
+			header: "",
+			wantGenerated: true,
+		},
+		{
+			name: "Propagated",
+			header: "lb-7f3a.42",
+		},
+		{
+			name: "Invalid Header",
+			header: "evil\" id",
+			wantGenerated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+			if test.header != "" {
+				r.Header.Set("X-Request-ID", test.header)
+			}
+
+			app.logRequest(next).ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+			if test.wantGenerated {
+				assert.Equal(t, len(id), 16)
+			} else {
+				assert.Equal(t, id, test.header)
+			}
+
+			assert.Equal(t, rr.Code, http.StatusNotFound)
+			assert.StringContains(t, rr.Body.String(), "Request ID: "+id)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"snippetbox.bimasenaputra/internal/migrations"
+)
+
+const migrateUsage = `usage: snippetbox migrate [flags] up|down|status|create <name>`
+
+// runMigrate implements the "migrate" subcommand. It reads the database
+// settings from the same environment variables and config file as the server.
+func runMigrate(args []string, lookupEnv func(string) (string, bool), out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	driver := fs.String("driver", "mysql", "Database driver (mysql or postgres)")
+	dsn := fs.String("dsn", "web:password@/snippetbox?parseTime=true", "Data Source Name")
+	dir := fs.String("dir", "./internal/migrations", "Migrations source directory, used by create")
+
+	err := loadFlags(fs, args, lookupEnv, false)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch fs.Arg(0) {
+	case "up", "down", "status", "create":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if fs.Arg(0) == "create" {
+		if fs.NArg() != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		paths, err := migrations.Create(*dir, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			fmt.Fprintln(out, "created", path)
+		}
+		return nil
+	}
+
+	db, err := openDB(*driver, *dsn)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	migrator, err := migrations.New(db, *driver)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch fs.Arg(0) {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+	case "down":
+		m, err := migrator.Down(ctx)
+		if errors.Is(err, migrations.ErrNoChange) {
+			fmt.Fprintln(out, "no migrations to roll back")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %04d_%s\n", m.Version, m.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = "applied " + humanDate(*s.Applied)
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	}
+
+	return nil
+}
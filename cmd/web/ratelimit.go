@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/models"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+// rateLimitConfig holds one budget per class of request. Each client gets its
+// own bucket per class, keyed by rateKey. A zero Burst turns limiting off for
+// the class.
+type rateLimitConfig struct {
+	Read   ratelimit.Budget
+	Write  ratelimit.Budget
+	Search ratelimit.Budget
+	// Idle is how long a bucket is kept after its client's last request.
+	Idle time.Duration
+	// TrustedProxies are the networks whose X-Forwarded-For header is
+	// believed when working out the client address.
+	TrustedProxies []*net.IPNet
+}
+
+func (c rateLimitConfig) validate() error {
+	for name, b := range map[string]ratelimit.Budget{"read": c.Read, "write": c.Write, "search": c.Search} {
+		if b.Burst < 0 || (b.Burst > 0 && b.Rate <= 0) {
+			return fmt.Errorf("invalid %s rate limit: rate must be positive and burst not negative", name)
+		}
+	}
+
+	if c.Idle <= 0 {
+		return fmt.Errorf("invalid rate limit idle timeout %s", c.Idle)
+	}
+
+	return nil
+}
+
+// budget returns the budget a request class is charged against.
+func (c rateLimitConfig) budget(class string) ratelimit.Budget {
+	switch class {
+	case "search":
+		return c.Search
+	case "write":
+		return c.Write
+	case "read":
+		return c.Read
+	default:
+		return ratelimit.Budget{}
+	}
+}
+
+const (
+	// rateStoreTimeout bounds how long a request waits on the shared store
+	// and rateStoreCooldown how long the store is bypassed after it failed.
+	rateStoreTimeout  = 100 * time.Millisecond
+	rateStoreCooldown = 30 * time.Second
+)
+
+// rateSweeper is a shared rate limit store that leaves deleting idle buckets
+// to a background worker, so that requests never wait on it.
+type rateSweeper interface {
+	ratelimit.Store
+	Sweep(context.Context) (int64, error)
+}
+
+// newRateStore returns the rate limit store called kind, and the worker that
+// sweeps it if it needs one. The database store is shared by every instance
+// using db and falls back to per-instance limits while the database is
+// failing.
+func (app *application) newRateStore(kind, driver string, db *sql.DB) (ratelimit.Store, func(context.Context), error) {
+	local := ratelimit.NewMemoryStore(app.rateLimits.Idle)
+
+	var shared rateSweeper
+
+	switch kind {
+	case "memory":
+		return local, nil, nil
+	case "database":
+		switch driver {
+		case "mysql":
+			shared = &models.RateLimitModel{DB: db, Idle: app.rateLimits.Idle}
+		case "postgres":
+			shared = &models.PostgresRateLimitModel{DB: db, Idle: app.rateLimits.Idle}
+		default:
+			return nil, nil, fmt.Errorf("unsupported database driver %q", driver)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit store %q", kind)
+	}
+
+	sweep := func(ctx context.Context) {
+		app.sweepRateLimits(ctx, shared, app.rateLimits.Idle)
+	}
+
+	return &ratelimit.Fallback{
+		Shared:   shared,
+		Local:    local,
+		Timeout:  rateStoreTimeout,
+		Cooldown: rateStoreCooldown,
+		OnError: func(err error) {
+			app.logger.Warn("rate limit store failed, limiting per instance", slog.String("error", err.Error()))
+		},
+	}, sweep, nil
+}
+
+// sweepRateLimits deletes the idle buckets of store every interval until ctx
+// is done. A failed sweep is only logged, since the next one catches up.
+func (app *application) sweepRateLimits(ctx context.Context, store rateSweeper, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		swept, err := store.Sweep(ctx)
+		if err != nil {
+			app.logger.Warn("sweeping idle rate limit buckets", slog.String("error", err.Error()))
+			continue
+		}
+
+		app.logger.Debug("swept idle rate limit buckets", slog.Int64("buckets", swept))
+	}
+}
+
+// rateClass sorts a request into the budget it is charged against. Probes
+// from the orchestrator aren't limited at all.
+func rateClass(r *http.Request) string {
+	switch {
+	case probePaths[r.URL.Path]:
+		return "probe"
+	case r.URL.Path == "/snippets/search":
+		return "search"
+	case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+		return "read"
+	default:
+		return "write"
+	}
+}
+
+const rateIdentityContextKey = contextKey("rateIdentity")
+
+// withRateIdentity records who r was authenticated as, such as a user or API
+// token ID, so that its requests are limited per identity rather than per
+// address. Authentication has to run before rateLimiter to call it. There
+// are no accounts yet, so for now nothing does and every client is limited
+// by its address.
+func withRateIdentity(r *http.Request, identity string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), rateIdentityContextKey, identity))
+}
+
+// rateKey returns the bucket r is charged to within class: the identity it
+// was authenticated as, or else its client address. Nothing the client can
+// pick freely, like an unchecked bearer token, is used, as a new value would
+// get a new bucket.
+func rateKey(r *http.Request, class string, trusted []*net.IPNet) string {
+	if identity, ok := r.Context().Value(rateIdentityContextKey).(string); ok && identity != "" {
+		return class + ":id:" + identity
+	}
+	return class + ":ip:" + clientIP(r, trusted)
+}
+
+// clientIP returns the address of the client that sent r. X-Forwarded-For is
+// only believed when the request came from a trusted proxy and is read from
+// the right, so a client can't get around its limit by sending the header
+// itself.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ipTrusted(ip, trusted) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+
+		ip = hop
+		if !ipTrusted(hop, trusted) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+func ipTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses a comma separated list of CIDRs and bare addresses.
+func parseNetworks(s string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", field)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, n)
+	}
+
+	return networks, nil
+}
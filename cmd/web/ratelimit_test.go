@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks("10.0.0.0/8, 192.0.2.1,2001:db8::/32")
+	assert.NilError(t, err)
+	assert.Equal(t, len(networks), 3)
+	assert.Equal(t, networks[1].String(), "192.0.2.1/32")
+
+	networks, err = parseNetworks("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(networks), 0)
+
+	_, err = parseNetworks("10.0.0.0/8,proxy")
+	assert.Equal(t, err != nil, true)
+}
+
+type countingSweeper struct {
+	ratelimit.Store
+	sweeps atomic.Int32
+	err    error
+}
+
+func (s *countingSweeper) Sweep(ctx context.Context) (int64, error) {
+	s.sweeps.Add(1)
+	return 0, s.err
+}
+
+func TestSweepRateLimits(t *testing.T) {
+	var logs bytes.Buffer
+
+	app := &application{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	store := &countingSweeper{err: errors.New("database is down")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		app.sweepRateLimits(ctx, store, time.Millisecond)
+		close(done)
+	}()
+
+	// Failures don't stop the worker, which keeps sweeping until cancelled.
+	for store.sweeps.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	assert.StringContains(t, logs.String(), "database is down")
+}
@@ -9,20 +9,41 @@ func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFoundError(w)
+		app.notFoundError(w, r)
 	})
 
-	fileServer := http.FileServer(http.Dir("./ui/static/"))
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.clientError(w, r, http.StatusMethodNotAllowed)
+	})
+
+	router.Handler(http.MethodGet, "/static/*filepath", recordRoute("/static/*filepath", http.StripPrefix("/static", app.static)))
 
-	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
+	// handle registers h for pattern, recording the pattern for metrics and
+	// applying any security headers particular to the route.
+	handle := func(method, pattern string, h http.HandlerFunc) {
+		router.Handler(method, pattern, recordRoute(pattern, app.routeSecurity(pattern, h)))
+	}
 
-	router.HandlerFunc(http.MethodGet, "/", app.home)
-	router.HandlerFunc(http.MethodGet, "/snippet/view/:id", app.snippetView)
-	router.HandlerFunc(http.MethodGet, "/snippet/create", app.snippetCreate)
-	router.HandlerFunc(http.MethodPost, "/snippet/create", app.snippetCreatePost)
-	router.HandlerFunc(http.MethodGet, "/snippets/latest", app.snippetLatest)
-	router.HandlerFunc(http.MethodGet, "/snippets/search", app.snippetSearch)
-	router.HandlerFunc(http.MethodPost, "/snippets/search", app.snippetSearchPost)
+	handle(http.MethodGet, "/healthz", app.healthz)
+	handle(http.MethodGet, "/readyz", app.readyz)
+
+	handle(http.MethodGet, "/", app.home)
+	handle(http.MethodGet, "/snippet/view/:id", app.snippetView)
+	handle(http.MethodGet, "/snippet/raw/:id/:name", app.snippetRaw)
+	handle(http.MethodGet, "/snippet/download/:id", app.snippetDownload)
+	handle(http.MethodGet, "/snippet/fork/:id", app.snippetFork)
+	handle(http.MethodGet, "/snippet/create", app.snippetCreate)
+	handle(http.MethodPost, "/snippet/create", app.snippetCreatePost)
+	handle(http.MethodGet, "/snippets/latest", app.snippetLatest)
+	handle(http.MethodGet, "/snippets/search", app.snippetSearch)
+	handle(http.MethodPost, "/snippets/search", app.snippetSearchPost)
+	handle(http.MethodGet, "/tags/:name", app.tagView)
+	handle(http.MethodPost, cspReportPath, app.cspReport)
 	
-	return app.recoverPanic(app.logRequest(secureHeaders(app.rateLimiter(router))))
+	var handler http.Handler = router
+	if app.dev {
+		handler = noCache(handler)
+	}
+
+	return app.traceRequest(app.logRequest(app.instrument(compress(app.secureHeaders(app.recoverPanic(app.rateLimiter(handler)))))))
 }
\ No newline at end of file
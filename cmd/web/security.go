@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// securityPolicy decides the security headers sent with every response.
+// Headers set to "" are left out.
+type securityPolicy struct {
+	HSTS hstsPolicy
+	// FrameAncestors are the sources allowed to frame pages, as in the
+	// frame-ancestors directive of the Content-Security-Policy.
+	FrameAncestors string
+	// EmbedRoutes is a comma separated list of route patterns, such as
+	// /snippet/view/:id, that EmbedAncestors may frame instead. It is empty
+	// by default: embedding is opt-in and no route is frameable until one is
+	// listed.
+	EmbedRoutes    string
+	EmbedAncestors string
+
+	ReferrerPolicy    string
+	PermissionsPolicy string
+	OpenerPolicy      string
+	ResourcePolicy    string
+	// CSPReportOnly sends the Content-Security-Policy as report-only.
+	CSPReportOnly bool
+}
+
+// hstsPolicy is the Strict-Transport-Security header, which is only sent
+// over HTTPS. A zero MaxAge leaves it out.
+type hstsPolicy struct {
+	MaxAge            time.Duration
+	IncludeSubdomains bool
+	Preload           bool
+}
+
+// defaultSecurity is the policy used unless the configuration changes it.
+var defaultSecurity = securityPolicy{
+	HSTS:              hstsPolicy{MaxAge: 365 * 24 * time.Hour},
+	FrameAncestors:    "'none'",
+	EmbedAncestors:    "*",
+	ReferrerPolicy:    "strict-origin-when-cross-origin",
+	PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+	OpenerPolicy:      "same-origin",
+	ResourcePolicy:    "same-origin",
+}
+
+// hstsPreloadMinAge is the shortest max-age browsers accept for preloading.
+const hstsPreloadMinAge = 365 * 24 * time.Hour
+
+func (p securityPolicy) validate() error {
+	var errs []error
+
+	if p.HSTS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("invalid hsts-max-age %s", p.HSTS.MaxAge))
+	}
+
+	if p.HSTS.Preload && (!p.HSTS.IncludeSubdomains || p.HSTS.MaxAge < hstsPreloadMinAge) {
+		errs = append(errs, errors.New("hsts-preload needs hsts-include-subdomains and an hsts-max-age of at least a year"))
+	}
+
+	if strings.TrimSpace(p.FrameAncestors) == "" || strings.TrimSpace(p.EmbedAncestors) == "" {
+		errs = append(errs, errors.New("frame-ancestors and embed-ancestors can't be empty"))
+	}
+
+	for _, route := range p.embedRoutes() {
+		if !strings.HasPrefix(route, "/") {
+			errs = append(errs, fmt.Errorf("invalid embed route %q", route))
+		}
+	}
+
+	for _, c := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"referrer-policy", p.ReferrerPolicy, []string{"", "no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin", "same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url"}},
+		{"cross-origin-opener-policy", p.OpenerPolicy, []string{"", "unsafe-none", "same-origin-allow-popups", "same-origin", "noopener-allow-popups"}},
+		{"cross-origin-resource-policy", p.ResourcePolicy, []string{"", "same-site", "same-origin", "cross-origin"}},
+	} {
+		if !slices.Contains(c.allowed, c.value) {
+			errs = append(errs, fmt.Errorf("invalid %s %q", c.name, c.value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p securityPolicy) embedRoutes() []string {
+	routes := []string{}
+	for _, route := range strings.Split(p.EmbedRoutes, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// forRoute returns the policy for the route registered as pattern, which
+// differs from p only for the routes other sites may embed.
+func (p securityPolicy) forRoute(pattern string) securityPolicy {
+	if slices.Contains(p.embedRoutes(), pattern) {
+		p.FrameAncestors = p.EmbedAncestors
+	}
+	return p
+}
+
+// apply sets the headers of the policy on h, removing those it leaves out.
+// nonce is the one the page's scripts carry and secure tells whether the
+// request came over HTTPS.
+func (p securityPolicy) apply(h http.Header, nonce string, secure bool) {
+	enforce, report := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+	if p.CSPReportOnly {
+		enforce, report = report, enforce
+	}
+
+	h.Set(enforce, contentSecurityPolicy(nonce, p.FrameAncestors))
+	h.Del(report)
+	h.Set("Reporting-Endpoints", `csp-endpoint="`+cspReportPath+`"`)
+
+	// Browsers that don't know frame-ancestors only understand these two
+	// cases.
+	switch p.FrameAncestors {
+	case "'none'":
+		h.Set("X-Frame-Options", "DENY")
+	case "'self'":
+		h.Set("X-Frame-Options", "SAMEORIGIN")
+	default:
+		h.Del("X-Frame-Options")
+	}
+
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	for name, value := range map[string]string{
+		"Referrer-Policy":              p.ReferrerPolicy,
+		"Permissions-Policy":           p.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy":   p.OpenerPolicy,
+		"Cross-Origin-Resource-Policy": p.ResourcePolicy,
+		"Strict-Transport-Security":    p.HSTS.header(secure),
+	} {
+		if value == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, value)
+		}
+	}
+}
+
+func (hsts hstsPolicy) header(secure bool) string {
+	if !secure || hsts.MaxAge <= 0 {
+		return ""
+	}
+
+	value := "max-age=" + strconv.FormatInt(int64(hsts.MaxAge/time.Second), 10)
+	if hsts.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if hsts.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// isSecure reports whether r came over HTTPS, either directly or through a
+// trusted proxy that says so in X-Forwarded-Proto.
+func isSecure(r *http.Request, trusted []*net.IPNet) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ipTrusted(ip, trusted) && r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// routeSecurity applies the policy for the route registered as pattern over
+// the one secureHeaders set, if the two differ.
+func (app *application) routeSecurity(pattern string, next http.Handler) http.Handler {
+	policy := app.security.forRoute(pattern)
+	if policy == app.security {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy.apply(w.Header(), cspNonce(r), isSecure(r, app.rateLimits.TrustedProxies))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// serverTimeouts bound how long a client may take over each part of a
+// request, so slow or stalled clients can't hold connections open.
+type serverTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+func newServer(addr string, h http.Handler, timeouts serverTimeouts, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
+
+// dbPoolConfig sizes the database connection pool. Zero leaves the
+// database/sql default in place.
+type dbPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (p dbPoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+
+	if p.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+}
+
+// listener is a server run by serve, with what it takes to start it.
+type listener struct {
+	name   string
+	server *http.Server
+	tls    bool
+}
+
+// shutdownConfig controls how serve stops. Delay keeps serving, with
+// readiness failing, so load balancers stop sending traffic before the
+// listeners close; Timeout bounds how long in-flight requests may drain.
+type shutdownConfig struct {
+	Delay   time.Duration
+	Timeout time.Duration
+}
+
+// serve runs the listeners until one of them fails or stop delivers a
+// signal, then drains them all: readiness starts failing, no new connections
+// are accepted and in-flight requests are given until the shutdown timeout
+// to complete. Background workers run until their context is cancelled,
+// which happens once the listeners are closed. It returns an error unless
+// everything stopped cleanly after a signal.
+func (app *application) serve(listeners []listener, workers []func(ctx context.Context), stop <-chan os.Signal, cfg shutdownConfig) error {
+	failed := make(chan error, len(listeners))
+	var running sync.WaitGroup
+
+	for _, l := range listeners {
+		running.Add(1)
+
+		go func() {
+			defer running.Done()
+
+			app.logger.Info("starting "+l.name, slog.String("addr", l.server.Addr), slog.Bool("tls", l.tls))
+
+			var err error
+			if l.tls {
+				err = l.server.ListenAndServeTLS("", "")
+			} else {
+				err = l.server.ListenAndServe()
+			}
+
+			if !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s: %w", l.name, err)
+			}
+		}()
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+
+	for _, work := range workers {
+		background.Add(1)
+
+		go func() {
+			defer background.Done()
+			work(workerCtx)
+		}()
+	}
+
+	var errs []error
+
+	select {
+	case s := <-stop:
+		app.logger.Info("shutting down", slog.String("signal", s.String()))
+	case err := <-failed:
+		app.logger.Error("shutting down", slog.String("error", err.Error()))
+		errs = append(errs, err)
+	}
+
+	app.health.shuttingDown.Store(true)
+
+	if cfg.Delay > 0 && len(errs) == 0 {
+		app.logger.Info("waiting for load balancers to notice", slog.Duration("delay", cfg.Delay))
+
+		select {
+		case <-time.After(cfg.Delay):
+		case s := <-stop:
+			app.logger.Warn("skipping shutdown delay", slog.String("signal", s.String()))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var draining sync.WaitGroup
+
+	for _, l := range listeners {
+		draining.Add(1)
+
+		go func() {
+			defer draining.Done()
+
+			err := l.server.Shutdown(ctx)
+			if err != nil {
+				l.server.Close()
+
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: requests still in flight were cut off: %w", l.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	draining.Wait()
+	running.Wait()
+
+	stopWorkers()
+	background.Wait()
+
+	for len(failed) > 0 {
+		errs = append(errs, <-failed)
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		app.logger.Info("stopped cleanly")
+	}
+
+	return err
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+// freeAddr returns a local address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// waitForServer blocks until addr accepts connections.
+func waitForServer(t *testing.T, addr string) {
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("nothing listening on %s", addr)
+}
+
+func TestServeDrains(t *testing.T) {
+	app := newTestApplication(t)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("done"))
+	})
+	mux.HandleFunc("/readyz", app.readyz)
+
+	addr := freeAddr(t)
+	server := newServer(addr, mux, serverTimeouts{ReadHeader: time.Second}, app.logger)
+
+	workerStopped := make(chan struct{})
+	worker := func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	}
+
+	stop := make(chan os.Signal, 1)
+	served := make(chan error)
+
+	go func() {
+		served <- app.serve([]listener{{name: "server", server: server}}, []func(context.Context){worker}, stop, shutdownConfig{Delay: 200 * time.Millisecond, Timeout: 5 * time.Second})
+	}()
+
+	waitForServer(t, addr)
+
+	slow := make(chan string)
+	go func() {
+		rs, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer rs.Body.Close()
+
+		body, _ := io.ReadAll(rs.Body)
+		slow <- string(body)
+	}()
+
+	<-entered
+	stop <- syscall.SIGTERM
+
+	// Readiness fails straight away, while the delay keeps the listener
+	// open for load balancers to notice.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, app.health.shuttingDown.Load(), true)
+
+	rs, err := http.Get("http://" + addr + "/readyz")
+	assert.NilError(t, err)
+	rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusServiceUnavailable)
+
+	select {
+	case <-workerStopped:
+		t.Fatal("worker stopped before the listeners")
+	default:
+	}
+
+	close(release)
+
+	assert.Equal(t, <-slow, "done")
+	assert.NilError(t, <-served)
+
+	select {
+	case <-workerStopped:
+	default:
+		t.Fatal("worker still running")
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	app := newTestApplication(t)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	addr := freeAddr(t)
+	server := newServer(addr, handler, serverTimeouts{}, app.logger)
+
+	stop := make(chan os.Signal, 1)
+	served := make(chan error)
+
+	go func() {
+		served <- app.serve([]listener{{name: "server", server: server}}, nil, stop, shutdownConfig{Timeout: 50 * time.Millisecond})
+	}()
+
+	waitForServer(t, addr)
+
+	go http.Get("http://" + addr)
+
+	<-entered
+	stop <- syscall.SIGTERM
+
+	err := <-served
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "requests still in flight were cut off")
+}
+
+func TestServeListenerFails(t *testing.T) {
+	app := newTestApplication(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer l.Close()
+
+	other := newServer(freeAddr(t), http.NotFoundHandler(), serverTimeouts{}, app.logger)
+	taken := newServer(l.Addr().String(), http.NotFoundHandler(), serverTimeouts{}, app.logger)
+
+	listeners := []listener{
+		{name: "server", server: other},
+		{name: "admin server", server: taken},
+	}
+
+	err = app.serve(listeners, nil, make(chan os.Signal), shutdownConfig{Timeout: time.Second})
+
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "admin server")
+	assert.StringContains(t, err.Error(), "address already in use")
+}
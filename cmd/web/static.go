@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// staticAssets serves the files under ui/static. Every file can also be
+// fetched under a name carrying a hash of its content, which browsers may
+// cache for good because a changed file gets a new name.
+type staticAssets struct {
+	// hashed maps a file to its hashed name and files maps it back.
+	hashed map[string]string
+	files  map[string]string
+	server http.Handler
+	// live serves the files as they are on disk: URLs aren't hashed and
+	// nothing is cached. It's used with -dev.
+	live bool
+	fsys fs.FS
+}
+
+func newLiveStaticAssets(fsys fs.FS) *staticAssets {
+	return &staticAssets{live: true, fsys: fsys, server: http.FileServer(http.FS(fsys))}
+}
+
+func newStaticAssets(fsys fs.FS) (*staticAssets, error) {
+	s := &staticAssets{
+		hashed: map[string]string{},
+		files:  map[string]string{},
+		server: http.FileServer(http.FS(fsys)),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		sum, err := fileDigest(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + sum[:12] + ext
+
+		s.hashed[name] = hashed
+		s.files[hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// url returns the content-hashed URL of the static file name. It's exposed to
+// templates as "static", so a reference to a missing file fails rendering
+// rather than producing a broken link.
+func (s *staticAssets) url(name string) (string, error) {
+	if s.live {
+		name = strings.TrimPrefix(name, "/")
+
+		_, err := fs.Stat(s.fsys, name)
+		if err != nil {
+			return "", fmt.Errorf("no static file %q", name)
+		}
+
+		return "/static/" + name, nil
+	}
+
+	hashed, ok := s.hashed[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", fmt.Errorf("no static file %q", name)
+	}
+
+	return "/static/" + hashed, nil
+}
+
+// ServeHTTP serves a file relative to the static directory. Hashed names are
+// cached for a year; plain names must be revalidated on every use.
+func (s *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.live {
+		w.Header().Set("Cache-Control", "no-store")
+		s.server.ServeHTTP(w, r)
+		return
+	}
+
+	name, ok := s.files[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		w.Header().Set("Cache-Control", "no-cache")
+		s.server.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/" + name
+	r2.URL.RawPath = ""
+
+	s.server.ServeHTTP(w, r2)
+}
+
+// uiDigest hashes the names and contents of every file in fsys. Pages embed
+// the templates and asset URLs, so it changes whenever a rendered page could.
+func uiDigest(fsys fs.FS) (string, error) {
+	h := sha256.New()
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		sum, err := fileDigest(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s %s\n", name, sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileDigest(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
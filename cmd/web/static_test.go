@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestStaticAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/main.css": {Data: []byte("body { color: black; }")},
+		"js/main.js":   {Data: []byte("console.log('hi');")},
+	}
+
+	assets, err := newStaticAssets(fsys)
+	assert.NilError(t, err)
+
+	url, err := assets.url("css/main.css")
+	assert.NilError(t, err)
+	assert.Equal(t, regexp.MustCompile(`^/static/css/main\.[0-9a-f]{12}\.css$`).MatchString(url), true)
+
+	_, err = assets.url("css/missing.css")
+	assert.Equal(t, err != nil, true)
+
+	fsys["css/main.css"] = &fstest.MapFile{Data: []byte("body { color: red; }")}
+
+	changed, err := newStaticAssets(fsys)
+	assert.NilError(t, err)
+
+	changedURL, err := changed.url("css/main.css")
+	assert.NilError(t, err)
+	assert.Equal(t, changedURL != url, true)
+}
+
+func TestStaticCaching(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	hashed, err := app.static.url("css/main.css")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCode  int
+		wantCache string
+	}{
+		{
+			name:      "Hashed",
+			path:      hashed,
+			wantCode:  http.StatusOK,
+			wantCache: "public, max-age=31536000, immutable",
+		},
+		{
+			name:      "Plain",
+			path:      "/static/css/main.css",
+			wantCode:  http.StatusOK,
+			wantCache: "no-cache",
+		},
+		{
+			name:     "Missing",
+			path:     "/static/css/main.0123456789ab.css",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.path)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCache != "" {
+				assert.Equal(t, header.Get("Cache-Control"), tt.wantCache)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, hashed)
+}
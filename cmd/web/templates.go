@@ -2,7 +2,8 @@ package main
 
 import (
 	"html/template"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"time"
 
 	"snippetbox.bimasenaputra/internal/models"
@@ -23,18 +24,56 @@ func add(i1, i2 int) int {
 var functions = template.FuncMap{
 	"humanDate": humanDate,
 	"add": add,
+	"languages": func() []string { return languages },
 }
 
 type templateData struct {
 	Snippet *models.Snippet
 	Snippets []*models.Snippet
+	Forks []*models.Snippet
 	Form any
-	HasNext bool
-	HasPrev bool
+	Pagination *pagination
+	Tag string
+	Tags []string
+	TagCloud []*tagCloudItem
+	Error *errorData
+	// CSPNonce lets the page's script elements run under the
+	// Content-Security-Policy.
+	CSPNonce string
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {	
-	pages, err := filepath.Glob("./ui/html/pages/*.html")
+type tagCloudItem struct {
+	Name string
+	Count int
+	Weight int
+}
+
+// pageLink points at another page of a listing. Href is the shareable full
+// page URL and HxGet the fragment htmx swaps in when JavaScript is enabled.
+type pageLink struct {
+	Href string
+	HxGet string
+}
+
+type pagination struct {
+	Number int
+	Pages int
+	Total int
+	Size int
+	Sizes []int
+	First *pageLink
+	Prev *pageLink
+	Next *pageLink
+	Last *pageLink
+}
+
+// newTemplateCache parses every page and fragment in fsys, the ui directory.
+// Templates link to static files through the "static" function so they get
+// their hashed URLs.
+func newTemplateCache(fsys fs.FS, assets *staticAssets) (map[string]*template.Template, error) {
+	funcs := template.FuncMap{"static": assets.url}
+
+	pages, err := fs.Glob(fsys, "html/pages/*.html")
 	if err != nil {
 		return nil, err
 	}
@@ -43,19 +82,15 @@ func newTemplateCache() (map[string]*template.Template, error) {
 
 	for _, page := range pages {
 
-		name := filepath.Base(page)
+		name := path.Base(page)
 
-		ts, err := template.New(page).Funcs(functions).ParseFiles("./ui/html/base.html")
-		if err != nil {
-			return nil, err
+		patterns := []string{
+			"html/base.html",
+			"html/partials/*.html",
+			page,
 		}
 
-		ts, err = ts.ParseGlob("./ui/html/partials/*.html")
-		if err != nil {
-			return nil, err
-		}
-
-		ts, err = ts.ParseFiles(page)
+		ts, err := template.New(name).Funcs(functions).Funcs(funcs).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -63,16 +98,16 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts 
 	}
 
-	fragments, err := filepath.Glob("./ui/html/fragments/*.html")
+	fragments, err := fs.Glob(fsys, "html/fragments/*.html")
 	if err != nil {
 		return nil, err
 	}
 
 	for _, fragment := range fragments {
 
-		name := filepath.Base(fragment)
+		name := path.Base(fragment)
 
-		ts, err := template.New(fragment).Funcs(functions).ParseFiles(fragment)
+		ts, err := template.New(name).Funcs(functions).Funcs(funcs).ParseFS(fsys, fragment, "html/partials/*.html")
 		if err != nil {
 			return nil, err
 		}
@@ -81,4 +116,4 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	}
 	
 	return cache, nil
-}
\ No newline at end of file
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"os"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/ui"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -37,4 +43,43 @@ func TestAdd(t *testing.T) {
 	actual := add(1, 2)
 	expected := 3
 	assert.Equal(t, actual, expected)
-}
\ No newline at end of file
+}
+
+func TestNewTemplateCache(t *testing.T) {
+	newCache := func(fsys fs.FS) map[string]*template.Template {
+		staticFiles, err := fs.Sub(fsys, "static")
+		assert.NilError(t, err)
+
+		assets, err := newStaticAssets(staticFiles)
+		assert.NilError(t, err)
+
+		cache, err := newTemplateCache(fsys, assets)
+		assert.NilError(t, err)
+
+		return cache
+	}
+
+	embedded := newCache(ui.Files)
+	disk := newCache(os.DirFS("../../ui"))
+
+	assert.Equal(t, len(disk), len(embedded))
+	for name := range embedded {
+		_, ok := disk[name]
+		assert.Equal(t, ok, true)
+	}
+
+	// A directory passed with -ui-dir is used as is, so edited templates
+	// show up without rebuilding.
+	edited := fstest.MapFS{
+		"html/base.html":           {Data: []byte(`{{define "base"}}<link href='{{static "css/main.css"}}'>{{template "main" .}}{{end}}`)},
+		"html/pages/home.html":     {Data: []byte(`{{define "main"}}Edited home{{end}}`)},
+		"html/partials/empty.html": {Data: []byte(`{{define "empty"}}{{end}}`)},
+		"static/css/main.css":      {Data: []byte("body {}")},
+	}
+
+	buf := new(bytes.Buffer)
+	err := newCache(edited)["home.html"].ExecuteTemplate(buf, "base", &templateData{})
+	assert.NilError(t, err)
+	assert.StringContains(t, buf.String(), "Edited home")
+	assert.StringContains(t, buf.String(), "/static/css/main.")
+}
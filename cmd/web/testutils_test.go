@@ -3,37 +3,43 @@ package main
 import (
 	"bytes"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path"
-	"runtime"
 	"testing"
+	"time"
 
 	"snippetbox.bimasenaputra/internal/mocks"
+	"snippetbox.bimasenaputra/ui"
 )
 
 func newTestApplication(t *testing.T) *application {
-	// Change path directory so it can fetch all required html files
-	_, filename, _, _ := runtime.Caller(0)
-
-    dir := path.Join(path.Dir(filename), "../..")
-    err := os.Chdir(dir)
-    if err != nil {
-        t.Fatal(err)
-    }  
-	
-	templateCache, err := newTemplateCache()
+	staticFiles, err := fs.Sub(ui.Files, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assets, err := newStaticAssets(staticFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateCache, err := newTemplateCache(ui.Files, assets)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	return &application{
-		errorLog: log.New(io.Discard, "", 0),
-		infoLog: log.New(io.Discard, "", 0),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		snippets: &mocks.SnippetModel{},
+		tags: &mocks.TagModel{},
 		templateCache: templateCache,
+		static: assets,
+		uiVersion: "test",
+		security: defaultSecurity,
+		cursors: &cursorCodec{key: []byte("test-cursor-key")},
+		health: &health{started: time.Now(), build: readBuildInfo()},
 	}
 }
 
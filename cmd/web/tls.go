@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// certReloader hands out the server certificate, which can be swapped for the
+// current contents of its files while the server is running. Connections
+// already established keep the certificate they were set up with.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// reload reads the certificate and key again. The old certificate stays in
+// use if they can't be loaded.
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.mu.Unlock()
+
+	return nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return cr.cert, nil
+}
+
+// newTLSConfig only allows TLS 1.2 and later with forward secret AEAD cipher
+// suites, and offers HTTP/2.
+func newTLSConfig(cr *certReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: cr.getCertificate,
+	}
+}
+
+// selfSignedValidity is how long a generated development certificate lasts.
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// ensureSelfSigned writes a self-signed certificate for localhost to
+// certFile and keyFile when neither exists. It reports whether one was
+// generated. If only one of them exists it fails rather than overwrite it,
+// since that file may belong to a real certificate.
+func ensureSelfSigned(certFile, keyFile string) (bool, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+
+	for _, err := range []error{certErr, keyErr} {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+
+	switch {
+	case certErr == nil && keyErr == nil:
+		return false, nil
+	case certErr == nil:
+		return false, fmt.Errorf("%s exists but %s doesn't; remove it or provide the key", certFile, keyFile)
+	case keyErr == nil:
+		return false, fmt.Errorf("%s exists but %s doesn't; remove it or provide the certificate", keyFile, certFile)
+	}
+
+	certPEM, keyPEM, err := selfSignedCert([]string{"localhost", "127.0.0.1", "::1"}, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{certFile, certPEM}, {keyFile, keyPEM}} {
+		err = os.MkdirAll(filepath.Dir(f.name), 0o700)
+		if err != nil {
+			return false, err
+		}
+
+		err = os.WriteFile(f.name, f.data, 0o600)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// selfSignedCert returns a PEM encoded ECDSA certificate and key valid for
+// hosts, which may be names or IP addresses.
+func selfSignedCert(hosts []string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Snippetbox development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL over HTTPS on the
+// port in tlsAddr.
+func redirectToHTTPS(tlsAddr string) (http.Handler, error) {
+	_, port, err := net.SplitHostPort(tlsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS address %q: %w", tlsAddr, err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	}), nil
+}
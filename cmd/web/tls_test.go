@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	generated, err := ensureSelfSigned(certFile, keyFile)
+	assert.NilError(t, err)
+	assert.Equal(t, generated, true)
+
+	info, err := os.Stat(keyFile)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NilError(t, err)
+	assert.NilError(t, cert.Leaf.VerifyHostname("localhost"))
+	assert.NilError(t, cert.Leaf.VerifyHostname("127.0.0.1"))
+
+	generated, err = ensureSelfSigned(certFile, keyFile)
+	assert.NilError(t, err)
+	assert.Equal(t, generated, false)
+
+	// With only one of the files there, neither is touched.
+	keyPEM, err := os.ReadFile(keyFile)
+	assert.NilError(t, err)
+	assert.NilError(t, os.Remove(certFile))
+
+	_, err = ensureSelfSigned(certFile, keyFile)
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "cert.pem doesn't")
+
+	_, err = os.Stat(certFile)
+	assert.Equal(t, errors.Is(err, fs.ErrNotExist), true)
+
+	after, err := os.ReadFile(keyFile)
+	assert.NilError(t, err)
+	assert.Equal(t, string(after), string(keyPEM))
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeCert := func() {
+		certPEM, keyPEM, err := selfSignedCert([]string{"localhost"}, time.Now())
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(certFile, certPEM, 0o600))
+		assert.NilError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	}
+
+	writeCert()
+
+	cr, err := newCertReloader(certFile, keyFile)
+	assert.NilError(t, err)
+
+	first, err := cr.getCertificate(nil)
+	assert.NilError(t, err)
+
+	writeCert()
+	assert.NilError(t, cr.reload())
+
+	second, err := cr.getCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, second.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) != 0, true)
+
+	assert.NilError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	assert.Equal(t, cr.reload() != nil, true)
+
+	current, err := cr.getCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, current == second, true)
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	_, err := ensureSelfSigned(certFile, keyFile)
+	assert.NilError(t, err)
+
+	cr, err := newCertReloader(certFile, keyFile)
+	assert.NilError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}),
+		TLSConfig: newTLSConfig(cr),
+	}
+	go server.ServeTLS(l, "", "")
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	cert, err := cr.getCertificate(nil)
+	assert.NilError(t, err)
+	roots.AddCert(cert.Leaf)
+
+	client := func(maxVersion uint16) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, MaxVersion: maxVersion},
+			ForceAttemptHTTP2: true,
+		}}
+	}
+
+	rs, err := client(0).Get("https://" + l.Addr().String())
+	assert.NilError(t, err)
+	rs.Body.Close()
+	assert.Equal(t, rs.ProtoMajor, 2)
+
+	rs, err = client(tls.VersionTLS12).Get("https://" + l.Addr().String())
+	assert.NilError(t, err)
+	rs.Body.Close()
+	assert.Equal(t, rs.TLS.Version, uint16(tls.VersionTLS12))
+
+	_, err = client(tls.VersionTLS11).Get("https://" + l.Addr().String())
+	assert.Equal(t, err != nil, true)
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name         string
+		tlsAddr      string
+		method       string
+		target       string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Default Port",
+			tlsAddr:      ":443",
+			method:       http.MethodGet,
+			target:       "http://example.com/snippet/view/1?x=1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "https://example.com/snippet/view/1?x=1",
+		},
+		{
+			name:         "Other Port",
+			tlsAddr:      ":4000",
+			method:       http.MethodGet,
+			target:       "http://example.com:8080/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "https://example.com:4000/",
+		},
+		{
+			name:         "Form Submission",
+			tlsAddr:      ":443",
+			method:       http.MethodPost,
+			target:       "http://example.com/snippet/create",
+			wantCode:     http.StatusPermanentRedirect,
+			wantLocation: "https://example.com/snippet/create",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := redirectToHTTPS(tt.tlsAddr)
+			assert.NilError(t, err)
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, rr.Header().Get("Location"), tt.wantLocation)
+		})
+	}
+
+	_, err := redirectToHTTPS("no port")
+	assert.Equal(t, err != nil, true)
+}
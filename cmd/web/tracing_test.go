@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/mocks"
+	"snippetbox.bimasenaputra/internal/models"
+)
+
+func TestTraceRequest(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	app := newTestApplication(t)
+	app.snippets = models.TraceSnippets(&mocks.SnippetModel{}, "mysql")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+	assert.NilError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rs, err := ts.Client().Do(req)
+	assert.NilError(t, err)
+	rs.Body.Close()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	server, ok := spans["GET /snippet/view/:id"]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, server.SpanContext.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, server.Parent.SpanID().String(), "00f067aa0ba902b7")
+
+	for _, name := range []string{"SnippetModel.Get", "SnippetModel.Forks", "render"} {
+		span, ok := spans[name]
+		assert.Equal(t, ok, true)
+		assert.Equal(t, span.SpanContext.TraceID(), server.SpanContext.TraceID())
+	}
+}
+
+func TestSetupTracing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := setupTracing(context.Background(), tracingConfig{Exporter: "file", File: path, SampleRatio: 1})
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	_, span := tracer().Start(context.Background(), "offline")
+	span.End()
+
+	assert.NilError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.StringContains(t, string(b), `"Name":"offline"`)
+
+	_, err = setupTracing(context.Background(), tracingConfig{Exporter: "zipkin"})
+	assert.Equal(t, err != nil, true)
+}
@@ -19,4 +19,12 @@ func StringContains(t *testing.T, actual, expectedSubstring string) {
 	if !strings.Contains(actual, expectedSubstring) {
 		t.Errorf("got: %q; expected to contain: %q", actual, expectedSubstring)
 	}
-}
\ No newline at end of file
+}
+
+func NilError(t *testing.T, actual error) {
+	t.Helper()
+
+	if actual != nil {
+		t.Errorf("got: %v; expected: nil", actual)
+	}
+}
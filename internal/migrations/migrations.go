@@ -0,0 +1,448 @@
+// Package migrations applies the versioned SQL scripts embedded from mysql/
+// and postgres/ and records each version applied in a migrations table.
+//
+// Every migration runs in one transaction with the row recording it, which
+// makes it atomic on PostgreSQL. MySQL commits implicitly around each DDL
+// statement, so there a migration is only atomic if it is a single DDL
+// statement or only changes data. The MySQL scripts keep to that, which is
+// why related changes are spread over several versions. Even then, if
+// recording the version fails after the DDL statement took effect, the
+// migration has to be recorded by hand before Up can run again.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql postgres/*.sql
+var files embed.FS
+
+var ErrNoChange = errors.New("migrations: no change")
+
+// lockName identifies the advisory lock held while migrations are applied, so
+// that several instances started with -auto-migrate don't race each other.
+const lockName = "snippetbox_migrations"
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied *time.Time
+}
+
+type dialect struct {
+	createTable string
+	tableExists string
+	insert      string
+	delete      string
+	lock        string
+	unlock      string
+}
+
+var dialects = map[string]dialect{
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied DATETIME NOT NULL
+		)`,
+		tableExists: `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'migrations'`,
+		insert: `INSERT INTO migrations (version, name, applied) VALUES(?, ?, UTC_TIMESTAMP())`,
+		delete: `DELETE FROM migrations WHERE version = ?`,
+		lock:   `SELECT GET_LOCK('` + lockName + `', -1)`,
+		unlock: `SELECT RELEASE_LOCK('` + lockName + `')`,
+	},
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied TIMESTAMP NOT NULL
+		)`,
+		tableExists: `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = 'migrations'`,
+		insert: `INSERT INTO migrations (version, name, applied) VALUES($1, $2, NOW() AT TIME ZONE 'UTC')`,
+		delete: `DELETE FROM migrations WHERE version = $1`,
+		lock:   `SELECT pg_advisory_lock(hashtext('` + lockName + `'))`,
+		unlock: `SELECT pg_advisory_unlock(hashtext('` + lockName + `'))`,
+	},
+}
+
+// Migrator applies the embedded migrations of one backend to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	dialect    dialect
+}
+
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("migrations: unsupported driver %q", driver)
+	}
+
+	sub, err := fs.Sub(files, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations, dialect: d}, nil
+}
+
+// Load reads every NNNN_name.up.sql/NNNN_name.down.sql pair in fsys and
+// returns them ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migrations: version %d is missing its up or down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err := m.exec(ctx, conn, migration.Up, m.dialect.insert, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migrations: applying %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration. It returns ErrNoChange
+// when there is nothing left to roll back.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			migration := m.Migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			err := m.exec(ctx, conn, migration.Down, m.dialect.delete, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrations: rolling back %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			rolledBack = &migration
+			return nil
+		}
+
+		return ErrNoChange
+	})
+
+	return rolledBack, err
+}
+
+// Status reports every known migration along with when it was applied. It
+// only reads, so it is safe to call from health checks: before the first
+// migration the tracking table doesn't exist and nothing has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var tables int
+
+	err = conn.QueryRowContext(ctx, m.dialect.tableExists).Scan(&tables)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[int]time.Time{}
+
+	if tables > 0 {
+		versions, err = m.versions(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := []Status{}
+
+	for _, migration := range m.Migrations {
+		s := Status{Migration: migration}
+		if applied, ok := versions[migration.Version]; ok {
+			s.Applied = &applied
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+
+	for _, s := range statuses {
+		if s.Applied == nil {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn) error) error {
+	// Advisory locks belong to a session, so lock, migrate and unlock all
+	// happen on the same connection.
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, m.dialect.lock)
+	if err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), m.dialect.unlock)
+
+	return fn(conn)
+}
+
+// applied creates the tracking table if needed and returns when each
+// applied version was applied.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	_, err := conn.ExecContext(ctx, m.dialect.createTable)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.versions(ctx, conn)
+}
+
+func (m *Migrator) versions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied FROM migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var applied time.Time
+
+		err := rows.Scan(&version, &applied)
+		if err != nil {
+			return nil, err
+		}
+
+		versions[version] = applied
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements breaks a migration script into individual statements so
+// that MySQL connections don't need multiStatements enabled. Statements are
+// terminated by a semicolon at the end of a line.
+func splitStatements(script string) []string {
+	stmts := []string{}
+	current := strings.Builder{}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+
+	return stmts
+}
+
+// Create writes an empty up/down pair named name for every backend under dir,
+// numbered one past the highest existing version, and returns the new paths.
+func Create(dir, name string) ([]string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return nil, fmt.Errorf("migrations: name %q must only contain letters, digits and underscores", name)
+	}
+
+	version := 0
+
+	for driver := range dialects {
+		migrations, err := Load(os.DirFS(filepath.Join(dir, driver)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		for _, m := range migrations {
+			if m.Version > version {
+				version = m.Version
+			}
+		}
+	}
+
+	version++
+
+	paths := []string{}
+
+	for driver := range dialects {
+		err := os.MkdirAll(filepath.Join(dir, driver), 0755)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, driver, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+
+			err := os.WriteFile(path, []byte(fmt.Sprintf("-- %04d_%s (%s)\n", version, name, direction)), 0644)
+			if err != nil {
+				return nil, err
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	versions := map[string][]int{}
+
+	for driver := range dialects {
+		sub, err := fs.Sub(files, driver)
+		assert.NilError(t, err)
+
+		migrations, err := Load(sub)
+		assert.NilError(t, err)
+
+		for i, m := range migrations {
+			assert.Equal(t, m.Version, i+1)
+			versions[driver] = append(versions[driver], m.Version)
+		}
+	}
+
+	assert.Equal(t, len(versions["mysql"]), len(versions["postgres"]))
+}
+
+// TestMySQLMigrationsAtomic checks that every MySQL script is a single DDL
+// statement or changes only data, since MySQL commits around DDL and a
+// script mixing them could be left half applied.
+func TestMySQLMigrationsAtomic(t *testing.T) {
+	sub, err := fs.Sub(files, "mysql")
+	assert.NilError(t, err)
+
+	migrations, err := Load(sub)
+	assert.NilError(t, err)
+
+	ddl := regexp.MustCompile(`(?i)^\s*(CREATE|ALTER|DROP|RENAME|TRUNCATE)\s`)
+
+	for _, m := range migrations {
+		for _, script := range []string{m.Up, m.Down} {
+			stmts := splitStatements(script)
+
+			for _, stmt := range stmts {
+				if ddl.MatchString(stmt) && len(stmts) > 1 {
+					t.Errorf("migration %d mixes %q with other statements", m.Version, strings.SplitN(stmt, "\n", 2)[0])
+				}
+			}
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements(`-- comment
+CREATE TABLE a (
+    id INTEGER
+);
+
+CREATE INDEX idx ON a(id);
+DROP TABLE b`)
+
+	assert.Equal(t, len(stmts), 3)
+	assert.Equal(t, stmts[0], "CREATE TABLE a (\n    id INTEGER\n)")
+	assert.Equal(t, stmts[1], "CREATE INDEX idx ON a(id)")
+	assert.Equal(t, stmts[2], "DROP TABLE b")
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Create(dir, "create_tags")
+	assert.NilError(t, err)
+	assert.Equal(t, len(paths), 4)
+	assert.Equal(t, paths[0], filepath.Join(dir, "mysql", "0001_create_tags.down.sql"))
+
+	paths, err = Create(dir, "add_forks")
+	assert.NilError(t, err)
+	assert.Equal(t, paths[3], filepath.Join(dir, "postgres", "0002_add_forks.up.sql"))
+
+	_, err = os.Stat(paths[3])
+	assert.NilError(t, err)
+
+	_, err = Create(dir, "bad name")
+	assert.Equal(t, err != nil, true)
+}
+
+// TestUpExistingSchema runs the migrations against a database that was set up
+// by hand from testdata/setup_<driver>.sql before migrations existed. The DSNs
+// are the ones the models integration tests use, and the database must not
+// have been migrated yet.
+func TestUpExistingSchema(t *testing.T) {
+	for driver, dsnEnv := range map[string]string{
+		"mysql":    "SNIPPETBOX_TEST_MYSQL_DSN",
+		"postgres": "SNIPPETBOX_TEST_POSTGRES_DSN",
+	} {
+		t.Run(driver, func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("migrations: skipping integration test")
+			}
+
+			dsn := os.Getenv(dsnEnv)
+			if dsn == "" {
+				t.Skipf("migrations: %s not set", dsnEnv)
+			}
+
+			db, err := sql.Open(driver, dsn)
+			assert.NilError(t, err)
+			defer db.Close()
+
+			ctx := context.Background()
+
+			setup, err := os.ReadFile(filepath.Join("testdata", "setup_"+driver+".sql"))
+			assert.NilError(t, err)
+
+			for _, stmt := range splitStatements(string(setup)) {
+				_, err := db.ExecContext(ctx, stmt)
+				assert.NilError(t, err)
+			}
+
+			migrator, err := New(db, driver)
+			assert.NilError(t, err)
+
+			t.Cleanup(func() {
+				for {
+					_, err := migrator.Down(ctx)
+					if errors.Is(err, ErrNoChange) {
+						break
+					} else if err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+
+			pending, err := migrator.Pending(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, pending, len(migrator.Migrations))
+
+			applied, err := migrator.Up(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, applied, len(migrator.Migrations))
+
+			pending, err = migrator.Pending(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, pending, 0)
+		})
+	}
+}
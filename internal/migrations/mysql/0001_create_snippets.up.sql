@@ -0,0 +1,11 @@
+-- Databases set up before migrations existed already have this table, so the
+-- baseline leaves it alone and is simply recorded as applied.
+CREATE TABLE IF NOT EXISTS SNIPPETS (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    INDEX idx_snippets_created (created),
+    FULLTEXT INDEX idx_snippets_title (title)
+);
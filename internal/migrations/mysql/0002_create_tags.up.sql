@@ -0,0 +1,5 @@
+CREATE TABLE TAGS (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(20) NOT NULL,
+    CONSTRAINT uc_tags_name UNIQUE (name)
+);
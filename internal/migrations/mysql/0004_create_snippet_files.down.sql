@@ -0,0 +1 @@
+DROP TABLE SNIPPET_FILES;
@@ -0,0 +1,11 @@
+CREATE TABLE SNIPPET_FILES (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    CONSTRAINT uc_snippet_files_position UNIQUE (snippet_id, position),
+    CONSTRAINT uc_snippet_files_name UNIQUE (snippet_id, name),
+    CONSTRAINT fk_snippet_files_snippet FOREIGN KEY (snippet_id) REFERENCES SNIPPETS(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+UPDATE SNIPPETS s
+JOIN SNIPPET_FILES f ON f.snippet_id = s.id AND f.position = 0
+SET s.content = f.content;
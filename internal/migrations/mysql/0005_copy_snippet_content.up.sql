@@ -0,0 +1,2 @@
+INSERT INTO SNIPPET_FILES (snippet_id, position, name, language, content)
+SELECT id, 0, 'snippet.txt', 'plaintext', content FROM SNIPPETS;
@@ -0,0 +1 @@
+ALTER TABLE SNIPPETS ADD COLUMN content TEXT NOT NULL;
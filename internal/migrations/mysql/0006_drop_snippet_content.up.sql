@@ -0,0 +1 @@
+ALTER TABLE SNIPPETS DROP COLUMN content;
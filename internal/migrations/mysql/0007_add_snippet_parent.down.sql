@@ -0,0 +1,4 @@
+-- Dropping the column drops idx_snippets_parent with it.
+ALTER TABLE SNIPPETS
+DROP FOREIGN KEY fk_snippets_parent,
+DROP COLUMN parent_id;
@@ -0,0 +1,4 @@
+ALTER TABLE SNIPPETS
+ADD COLUMN parent_id INTEGER NULL,
+ADD INDEX idx_snippets_parent (parent_id),
+ADD CONSTRAINT fk_snippets_parent FOREIGN KEY (parent_id) REFERENCES SNIPPETS(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+CREATE TABLE RATE_LIMITS (
+    bucket VARCHAR(255) NOT NULL PRIMARY KEY,
+    tokens DOUBLE NOT NULL,
+    updated DATETIME(6) NOT NULL,
+    INDEX idx_rate_limits_updated (updated)
+);
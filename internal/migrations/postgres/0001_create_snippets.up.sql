@@ -0,0 +1,13 @@
+-- Databases set up before migrations existed already have this table, so the
+-- baseline leaves it alone and is simply recorded as applied.
+CREATE TABLE IF NOT EXISTS snippets (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    title_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', title)) STORED
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets(created);
+CREATE INDEX IF NOT EXISTS idx_snippets_title_tsv ON snippets USING GIN(title_tsv);
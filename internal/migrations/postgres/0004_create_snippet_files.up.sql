@@ -0,0 +1,10 @@
+CREATE TABLE snippet_files (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL,
+    content TEXT NOT NULL,
+    UNIQUE (snippet_id, position),
+    UNIQUE (snippet_id, name)
+);
@@ -0,0 +1,3 @@
+UPDATE snippets s SET content = f.content
+FROM snippet_files f
+WHERE f.snippet_id = s.id AND f.position = 0;
@@ -0,0 +1,2 @@
+INSERT INTO snippet_files (snippet_id, position, name, language, content)
+SELECT id, 0, 'snippet.txt', 'plaintext', content FROM snippets;
@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN content TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE snippets ALTER COLUMN content DROP DEFAULT;
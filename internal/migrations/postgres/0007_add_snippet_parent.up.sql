@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL REFERENCES snippets(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_parent ON snippets(parent_id);
@@ -0,0 +1,7 @@
+CREATE TABLE rate_limits (
+    bucket VARCHAR(255) NOT NULL PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_updated ON rate_limits(updated);
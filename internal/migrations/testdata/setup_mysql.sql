@@ -0,0 +1,10 @@
+CREATE TABLE SNIPPETS (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON SNIPPETS(created);
+CREATE FULLTEXT INDEX idx_snippets_title ON SNIPPETS(title);
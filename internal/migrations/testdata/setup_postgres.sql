@@ -0,0 +1,11 @@
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    title_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', title)) STORED
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_title_tsv ON snippets USING GIN(title_tsv);
@@ -1,7 +1,8 @@
 package mocks
 
 import (
-	"database/sql"
+	"context"
+	"sync"
 	"time"
 
 	"snippetbox.bimasenaputra/internal/models"
@@ -10,107 +11,109 @@ import (
 var mockSnippet = &models.Snippet{
 	ID: 1,
 	Title: "An old silent pond",
-	Content: "An old silent pond...",
+	Files: []*models.SnippetFile{
+		{Name: "haiku.txt", Language: "plaintext", Content: "An old silent pond..."},
+		{Name: "notes.md", Language: "markdown", Content: "# Basho"},
+	},
 	Created: time.Now(),
 	Expires: time.Now(),
 }
 
-type SnippetModel struct{}
-
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
-	return 1, nil
+// mockFork was forked from mockSnippet and mockOrphan from a snippet that
+// has since expired.
+var mockFork = &models.Snippet{
+	ID: 3,
+	Title: "A frog jumps in",
+	Files: []*models.SnippetFile{
+		{Name: "haiku.txt", Language: "plaintext", Content: "A frog jumps in..."},
+	},
+	Created: time.Now(),
+	Expires: time.Now(),
+	Parent: &models.SnippetRef{ID: 1, Title: "An old silent pond", Live: true},
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
-		case 1:
-			return mockSnippet, nil
-		default:
-			return nil, sql.ErrNoRows
-	}
+var mockOrphan = &models.Snippet{
+	ID: 4,
+	Title: "The sound of water",
+	Files: []*models.SnippetFile{
+		{Name: "haiku.txt", Language: "plaintext", Content: "The sound of water..."},
+	},
+	Created: time.Now(),
+	Expires: time.Now(),
+	Parent: &models.SnippetRef{ID: 2},
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+type SnippetModel struct {
+	mu   sync.Mutex
+	tags []string
 }
 
-func (m *SnippetModel) GetMaxID() (int, error) {
-	return 1, nil
-}
+func (m *SnippetModel) Insert(ctx context.Context, title string, files []*models.SnippetFile, tags []string, expires int, parentID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-func (m *SnippetModel) GetMinID() (int, error) {
+	m.tags = tags
 	return 1, nil
 }
 
-func (m *SnippetModel) NextLatestPaging(id int) ([]*models.Snippet, error) {
-	switch id {
-	case 2:
-		return []*models.Snippet{mockSnippet}, nil
-	default:
-		return nil, nil
-	}
+// InsertedTags returns the tags of the last snippet inserted.
+func (m *SnippetModel) InsertedTags() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tags
 }
 
-func (m *SnippetModel) PrevLatestPaging(id int) ([]*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
-	case 0:
-		return []*models.Snippet{mockSnippet}, nil
-	default:
-		return nil, nil
+		case 1:
+			return mockSnippet, nil
+		case 3:
+			return mockFork, nil
+		case 4:
+			return mockOrphan, nil
+		default:
+			return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) LatestContainsTitle(title string) ([]*models.Snippet, error) {
-	switch title {
-	case "Old":
-		return []*models.Snippet{mockSnippet}, nil
-	default:
-		return nil, nil
+// Exists knows of the live snippets and of snippet 2, which has expired.
+func (m *SnippetModel) Exists(ctx context.Context, id int) (bool, error) {
+	switch id {
+		case 1, 2, 3, 4:
+			return true, nil
+		default:
+			return false, nil
 	}
 }
 
-func (m *SnippetModel) GetMaxIDByTitle(title string) (int, error) {
-	switch title {
-	case "Old":
-		return 1, nil
-	default:
-		return 0, nil
+func (m *SnippetModel) Page(ctx context.Context, filter models.SnippetFilter) (*models.SnippetPage, error) {
+	if filter.Tag != "" && filter.Tag != "haiku" {
+		return &models.SnippetPage{Snippets: []*models.Snippet{}, Limit: filter.Limit}, nil
 	}
-}
 
-func (m *SnippetModel) GetMinIDByTitle(title string) (int, error) {
-	switch title {
-	case "Old":
-		return 1, nil
+	switch filter.Title {
+	case "", "Old":
+		return &models.SnippetPage{
+			Snippets: []*models.Snippet{mockSnippet},
+			HasPrev: filter.Cursor != nil,
+			Total: 1,
+			Limit: filter.Limit,
+		}, nil
 	default:
-		return 0, nil
+		return &models.SnippetPage{Snippets: []*models.Snippet{}, Limit: filter.Limit}, nil
 	}
 }
 
-func (m *SnippetModel) NextLatestContainsTitle(id int, title string) ([]*models.Snippet, error) {
+func (m *SnippetModel) Forks(ctx context.Context, id int, limit int) ([]*models.Snippet, error) {
 	switch id {
-	case 2:
-		switch title {
-		case "Old":
-			return []*models.Snippet{mockSnippet}, nil
+		case 1:
+			return []*models.Snippet{mockFork}, nil
 		default:
-			return nil, nil
-		}
-	default:
-		return nil, nil
+			return []*models.Snippet{}, nil
 	}
 }
 
-func (m *SnippetModel) PrevLatestContainsTitle(id int, title string) ([]*models.Snippet, error) {
-	switch id {
-	case 0:
-		switch title {
-		case "Old":
-			return []*models.Snippet{mockSnippet}, nil
-		default:
-			return nil, nil
-		}
-	default:
-		return nil, nil
-	}
-}
\ No newline at end of file
+func (m *SnippetModel) Counts(ctx context.Context) (int, int, error) {
+	return 3, 1, nil
+}
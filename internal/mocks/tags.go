@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+
+	"snippetbox.bimasenaputra/internal/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) ForSnippet(ctx context.Context, snippetID int) ([]string, error) {
+	switch snippetID {
+	case 1:
+		return []string{"haiku", "poetry"}, nil
+	default:
+		return []string{}, nil
+	}
+}
+
+func (m *TagModel) Cloud(ctx context.Context, limit int) ([]*models.TagCount, error) {
+	return []*models.TagCount{
+		{Name: "haiku", Count: 2},
+		{Name: "poetry", Count: 1},
+	}, nil
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql"
+
+	"snippetbox.bimasenaputra/internal/util"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 50
+)
+
+// Cursor marks a position in a listing. A page is read either after ID (older
+// snippets) or, when Prev is set, before it (newer snippets).
+type Cursor struct {
+	ID   int
+	Prev bool
+}
+
+// SnippetFilter selects which live snippets Page returns. Empty Title and
+// Tag match every snippet and a nil Cursor starts from the newest one. Last
+// jumps to the page holding the oldest snippets and ignores Cursor.
+type SnippetFilter struct {
+	Title  string
+	Tag    string
+	Cursor *Cursor
+	Limit  int
+	Last   bool
+}
+
+type SnippetPage struct {
+	Snippets []*Snippet
+	HasNext  bool
+	HasPrev  bool
+	// Total is the number of snippets matching the filter, Offset how many
+	// of them come before this page and Limit the page size.
+	Total  int
+	Offset int
+	Limit  int
+}
+
+// NextCursor returns the cursor of the page following p.
+func (p *SnippetPage) NextCursor() *Cursor {
+	if len(p.Snippets) == 0 {
+		return nil
+	}
+	return &Cursor{ID: p.Snippets[len(p.Snippets)-1].ID}
+}
+
+// PrevCursor returns the cursor of the page preceding p.
+func (p *SnippetPage) PrevCursor() *Cursor {
+	if len(p.Snippets) == 0 {
+		return nil
+	}
+	return &Cursor{ID: p.Snippets[0].ID, Prev: true}
+}
+
+// Number returns the 1-based position of p among Pages.
+func (p *SnippetPage) Number() int {
+	if p.Limit <= 0 {
+		return 1
+	}
+
+	n := (p.Offset+p.Limit-1)/p.Limit + 1
+	if n > p.Pages() {
+		return p.Pages()
+	}
+	return n
+}
+
+// Pages returns how many pages of Limit snippets Total spans.
+func (p *SnippetPage) Pages() int {
+	if p.Limit <= 0 || p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.Limit - 1) / p.Limit
+}
+
+func (f SnippetFilter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if f.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return f.Limit
+}
+
+// position resolves the filter into the cursor and row count to fetch.
+// The last page is read backwards from the oldest snippet and is sized so
+// that the pages before it are all full.
+func (f SnippetFilter) position(total int) (*Cursor, int) {
+	if !f.Last {
+		return f.Cursor, f.limit()
+	}
+
+	size := total % f.limit()
+	if size == 0 {
+		size = f.limit()
+	}
+
+	return &Cursor{ID: 0, Prev: true}, size
+}
+
+// threshold returns the ID above which snippets are counted as coming before
+// the cursor, which is what numbering the page needs.
+func (f SnippetFilter) threshold() int {
+	cursor, _ := f.position(0)
+
+	switch {
+	case cursor == nil:
+		return 0
+	case cursor.Prev:
+		return cursor.ID
+	default:
+		return cursor.ID - 1
+	}
+}
+
+// newSnippetPage builds a page from rows fetched with limit+1 in the cursor's
+// direction. The extra row tells whether there is more in that direction;
+// total and newer (the count of snippets above threshold) place the page
+// within the whole listing.
+func newSnippetPage(snippets []*Snippet, cursor *Cursor, limit int, filter SnippetFilter, total, newer int) *SnippetPage {
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+
+	page := &SnippetPage{Snippets: snippets, Total: total, Limit: filter.limit()}
+
+	switch {
+	case cursor == nil:
+		page.HasNext = more
+	case cursor.Prev:
+		util.Reverse(page.Snippets)
+		page.Offset = newer - len(snippets)
+		if page.Offset < 0 {
+			page.Offset = 0
+		}
+		page.HasPrev = more
+		page.HasNext = page.Offset+len(snippets) < total
+	default:
+		page.Offset = newer
+		page.HasPrev = newer > 0
+		page.HasNext = more
+	}
+
+	return page
+}
+
+// PageOf pages through snippets, held newest first, the way the database
+// models page through their tables. It is for stores kept in memory.
+func PageOf(snippets []*Snippet, filter SnippetFilter) *SnippetPage {
+	threshold, newer := filter.threshold(), 0
+	for _, s := range snippets {
+		if s.ID > threshold {
+			newer++
+		}
+	}
+
+	cursor, limit := filter.position(len(snippets))
+	rows := []*Snippet{}
+
+	switch {
+	case cursor == nil:
+		rows = append(rows, snippets[:min(limit+1, len(snippets))]...)
+	case cursor.Prev:
+		// Oldest first, like ORDER BY id ASC.
+		for i := len(snippets) - 1; i >= 0 && len(rows) <= limit; i-- {
+			if snippets[i].ID > cursor.ID {
+				rows = append(rows, snippets[i])
+			}
+		}
+	default:
+		for _, s := range snippets {
+			if s.ID < cursor.ID && len(rows) <= limit {
+				rows = append(rows, s)
+			}
+		}
+	}
+
+	return newSnippetPage(rows, cursor, limit, filter, len(snippets), newer)
+}
+
+func scanSnippets(rows *sql.Rows) ([]*Snippet, error) {
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err := rows.Scan(&s.ID, &s.Title, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
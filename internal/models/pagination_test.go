@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+func TestSnippetPageNumbering(t *testing.T) {
+	tests := []struct {
+		name string
+		page SnippetPage
+		number int
+		pages int
+	} {
+		{
+			name: "Empty",
+			page: SnippetPage{Limit: 10},
+			number: 1,
+			pages: 1,
+		},
+		{
+			name: "First",
+			page: SnippetPage{Total: 25, Offset: 0, Limit: 10},
+			number: 1,
+			pages: 3,
+		},
+		{
+			name: "Aligned",
+			page: SnippetPage{Total: 25, Offset: 10, Limit: 10},
+			number: 2,
+			pages: 3,
+		},
+		{
+			name: "Unaligned",
+			page: SnippetPage{Total: 25, Offset: 4, Limit: 10},
+			number: 2,
+			pages: 3,
+		},
+		{
+			name: "Past End",
+			page: SnippetPage{Total: 20, Offset: 25, Limit: 10},
+			number: 2,
+			pages: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.page.Number(), test.number)
+			assert.Equal(t, test.page.Pages(), test.pages)
+		})
+	}
+}
+
+func TestSnippetFilterPosition(t *testing.T) {
+	cursor, limit := SnippetFilter{Last: true, Limit: 10}.position(23)
+	assert.Equal(t, *cursor, Cursor{ID: 0, Prev: true})
+	assert.Equal(t, limit, 3)
+
+	cursor, limit = SnippetFilter{Last: true, Limit: 10}.position(20)
+	assert.Equal(t, cursor.Prev, true)
+	assert.Equal(t, limit, 10)
+
+	cursor, limit = SnippetFilter{Limit: 100}.position(20)
+	assert.Equal(t, cursor == nil, true)
+	assert.Equal(t, limit, MaxPageSize)
+
+	assert.Equal(t, SnippetFilter{Cursor: &Cursor{ID: 5}}.threshold(), 4)
+	assert.Equal(t, SnippetFilter{Cursor: &Cursor{ID: 5, Prev: true}}.threshold(), 5)
+}
+
+// liveIDs turns random bytes into a set of snippet IDs, newest first.
+func liveIDs(raw []uint8) ([]int, []*Snippet) {
+	ids := []int{}
+	for _, b := range raw {
+		if id := int(b) + 1; !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+
+	snippets := []*Snippet{}
+	for _, id := range ids {
+		snippets = append(snippets, &Snippet{ID: id})
+	}
+	return ids, snippets
+}
+
+func pageIDs(page *SnippetPage) []int {
+	ids := []int{}
+	for _, s := range page.Snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// checkPage returns what is wrong with page as part of the listing of ids,
+// or "" if nothing is.
+func checkPage(ids []int, page *SnippetPage) string {
+	shown := pageIDs(page)
+
+	if len(shown) > page.Limit {
+		return fmt.Sprintf("%d snippets on a page of %d", len(shown), page.Limit)
+	}
+	if page.Total != len(ids) {
+		return fmt.Sprintf("total %d, want %d", page.Total, len(ids))
+	}
+	if len(shown) == 0 {
+		return ""
+	}
+
+	// The page is a run of the listing starting at Offset.
+	start := slices.Index(ids, shown[0])
+	if start != page.Offset || start+len(shown) > len(ids) || !slices.Equal(ids[start:start+len(shown)], shown) {
+		return fmt.Sprintf("page %v at offset %d isn't part of %v", shown, page.Offset, ids)
+	}
+
+	if page.HasPrev != (start > 0) || page.HasNext != (start+len(shown) < len(ids)) {
+		return fmt.Sprintf("page %v of %v has HasPrev %t, HasNext %t", shown, ids, page.HasPrev, page.HasNext)
+	}
+
+	if n := page.Number(); n < 1 || n > page.Pages() {
+		return fmt.Sprintf("page %d of %d", n, page.Pages())
+	}
+
+	return ""
+}
+
+func TestPageOfWalk(t *testing.T) {
+	// Paging through a listing visits every snippet once, newest first,
+	// whichever end it starts from, numbering the pages 1 to Pages.
+	property := func(raw []uint8, size uint8) bool {
+		ids, snippets := liveIDs(raw)
+		limit := int(size)%5 + 1
+
+		walk := func(filter SnippetFilter, backward bool) []int {
+			pages := [][]int{}
+
+			for len(pages) <= len(ids) {
+				page := PageOf(snippets, filter)
+				if problem := checkPage(ids, page); problem != "" {
+					t.Log(problem)
+					return nil
+				}
+
+				want := len(pages) + 1
+				if backward {
+					want = page.Pages() - len(pages)
+				}
+				if page.Number() != want {
+					t.Logf("page %v numbered %d, want %d", pageIDs(page), page.Number(), want)
+					return nil
+				}
+
+				pages = append(pages, pageIDs(page))
+
+				if !backward && page.HasNext {
+					filter = SnippetFilter{Cursor: page.NextCursor(), Limit: limit}
+				} else if backward && page.HasPrev {
+					filter = SnippetFilter{Cursor: page.PrevCursor(), Limit: limit}
+				} else {
+					break
+				}
+			}
+
+			if backward {
+				slices.Reverse(pages)
+			}
+			return slices.Concat(pages...)
+		}
+
+		forward := walk(SnippetFilter{Limit: limit}, false)
+		backward := walk(SnippetFilter{Last: true, Limit: limit}, true)
+
+		if !slices.Equal(forward, ids) || !slices.Equal(backward, ids) {
+			t.Logf("ids %v, forward %v, backward %v", ids, forward, backward)
+			return false
+		}
+		return true
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPageOfAnyCursor(t *testing.T) {
+	// A cursor may point at a snippet that has since expired, or past either
+	// end. The page is still placed correctly and is only empty when nothing
+	// is left in its direction.
+	property := func(raw []uint8, size uint8, id uint8, prev bool) bool {
+		ids, snippets := liveIDs(raw)
+		cursor := &Cursor{ID: int(id) + 1, Prev: prev}
+
+		page := PageOf(snippets, SnippetFilter{Cursor: cursor, Limit: int(size)%5 + 1})
+		if problem := checkPage(ids, page); problem != "" {
+			t.Logf("cursor %+v: %s", *cursor, problem)
+			return false
+		}
+
+		beyond := slices.ContainsFunc(ids, func(id int) bool {
+			return (prev && id > cursor.ID) || (!prev && id < cursor.ID)
+		})
+		if (len(page.Snippets) > 0) != beyond {
+			t.Logf("cursor %+v over %v gave %v", *cursor, ids, pageIDs(page))
+			return false
+		}
+		return true
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+// RateLimitModel is a ratelimit.Store kept in MySQL, so that every instance
+// sharing the database draws from the same buckets. The database clock is
+// used throughout so skew between instances doesn't matter.
+type RateLimitModel struct {
+	DB *sql.DB
+	// Idle is how long a bucket is kept after it was last used.
+	Idle time.Duration
+}
+
+func (m *RateLimitModel) Take(ctx context.Context, key string, budget ratelimit.Budget) (ratelimit.Decision, error) {
+	d, err := m.take(ctx, key, budget)
+	return d, contended(err)
+}
+
+func (m *RateLimitModel) take(ctx context.Context, key string, budget ratelimit.Budget) (ratelimit.Decision, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	defer tx.Rollback()
+
+	// INSERT IGNORE would take a shared lock on an existing bucket, and two
+	// requests holding one each deadlock on the FOR UPDATE below. The no-op
+	// update takes the exclusive lock straight away instead.
+	_, err = tx.ExecContext(ctx, `INSERT INTO RATE_LIMITS (bucket, tokens, updated)
+	VALUES(?, ?, UTC_TIMESTAMP(6)) ON DUPLICATE KEY UPDATE bucket = bucket`, key, budget.Burst)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	b := &ratelimit.Bucket{}
+	var now time.Time
+
+	err = tx.QueryRowContext(ctx, `SELECT tokens, updated, UTC_TIMESTAMP(6) FROM RATE_LIMITS
+	WHERE bucket = ? FOR UPDATE`, key).Scan(&b.Tokens, &b.Updated, &now)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	d := b.Take(budget, now)
+
+	_, err = tx.ExecContext(ctx, `UPDATE RATE_LIMITS SET tokens = ?, updated = ? WHERE bucket = ?`,
+		b.Tokens, b.Updated, key)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	return d, nil
+}
+
+// Sweep deletes the buckets that have been idle for longer than Idle and
+// returns how many there were. Take leaves this to a background worker so
+// that requests never wait on it.
+func (m *RateLimitModel) Sweep(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM RATE_LIMITS
+	WHERE updated < UTC_TIMESTAMP(6) - INTERVAL ? MICROSECOND`, m.Idle.Microseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// contended wraps err with ratelimit.ErrContended when the database gave up
+// on a bucket because of other transactions: MySQL deadlocks and lock wait
+// timeouts, and PostgreSQL deadlocks, serialization failures and lock
+// timeouts.
+func contended(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205) {
+		return fmt.Errorf("%w: %w", ratelimit.ErrContended, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40P01", "40001", "55P03":
+			return fmt.Errorf("%w: %w", ratelimit.ErrContended, err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+// PostgresRateLimitModel is the PostgreSQL implementation of RateLimitModel.
+type PostgresRateLimitModel struct {
+	DB   *sql.DB
+	Idle time.Duration
+}
+
+func (m *PostgresRateLimitModel) Take(ctx context.Context, key string, budget ratelimit.Budget) (ratelimit.Decision, error) {
+	d, err := m.take(ctx, key, budget)
+	return d, contended(err)
+}
+
+func (m *PostgresRateLimitModel) take(ctx context.Context, key string, budget ratelimit.Budget) (ratelimit.Decision, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO rate_limits (bucket, tokens, updated)
+	VALUES($1, $2, NOW() AT TIME ZONE 'UTC') ON CONFLICT (bucket) DO NOTHING`, key, budget.Burst)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	b := &ratelimit.Bucket{}
+	var now time.Time
+
+	err = tx.QueryRowContext(ctx, `SELECT tokens, updated, NOW() AT TIME ZONE 'UTC' FROM rate_limits
+	WHERE bucket = $1 FOR UPDATE`, key).Scan(&b.Tokens, &b.Updated, &now)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	d := b.Take(budget, now)
+
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limits SET tokens = $1, updated = $2 WHERE bucket = $3`,
+		b.Tokens, b.Updated, key)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	return d, nil
+}
+
+func (m *PostgresRateLimitModel) Sweep(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM rate_limits
+	WHERE updated < (NOW() AT TIME ZONE 'UTC') - $1 * INTERVAL '1 microsecond'`, m.Idle.Microseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
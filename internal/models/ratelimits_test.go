@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"snippetbox.bimasenaputra/internal/assert"
+	"snippetbox.bimasenaputra/internal/ratelimit"
+)
+
+// sweeper is implemented by the shared stores, which delete idle buckets on
+// request rather than in Take.
+type sweeper interface {
+	ratelimit.Store
+	Sweep(context.Context) (int64, error)
+}
+
+// testRateLimitModel is the conformance suite for the shared rate limit
+// stores. newStore must return a store backed by an empty table that keeps
+// idle buckets for idle.
+func testRateLimitModel(t *testing.T, newStore func(t *testing.T, idle time.Duration) sweeper) {
+	t.Run("Take", func(t *testing.T) {
+		s := newStore(t, time.Hour)
+		ctx := context.Background()
+
+		// The rate is low enough that nothing refills during the test.
+		budget := ratelimit.Budget{Rate: 0.001, Burst: 2}
+
+		for i := 0; i < 2; i++ {
+			d, err := s.Take(ctx, "read:192.0.2.1", budget)
+			assert.NilError(t, err)
+			assert.Equal(t, d.Allowed, true)
+			assert.Equal(t, d.Limit, 2)
+			assert.Equal(t, d.Remaining, 1-i)
+		}
+
+		d, err := s.Take(ctx, "read:192.0.2.1", budget)
+		assert.NilError(t, err)
+		assert.Equal(t, d.Allowed, false)
+		assert.Equal(t, d.RetryAfter > 0, true)
+
+		d, err = s.Take(ctx, "read:192.0.2.2", budget)
+		assert.NilError(t, err)
+		assert.Equal(t, d.Allowed, true)
+
+		swept, err := s.Sweep(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, swept, int64(0))
+	})
+
+	t.Run("Concurrent Same Key", func(t *testing.T) {
+		s := newStore(t, time.Hour)
+		ctx := context.Background()
+
+		budget := ratelimit.Budget{Rate: 0.001, Burst: 5}
+
+		// The first request creates the bucket; the rest race for it, like
+		// parallel asset loads from one client.
+		_, err := s.Take(ctx, "read:192.0.2.1", budget)
+		assert.NilError(t, err)
+
+		const n = 20
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+			errs    []error
+		)
+
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				d, err := s.Take(ctx, "read:192.0.2.1", budget)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, err)
+				} else if d.Allowed {
+					allowed++
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		for _, err := range errs {
+			t.Error(err)
+		}
+		assert.Equal(t, allowed, budget.Burst-1)
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		s := newStore(t, time.Millisecond)
+		ctx := context.Background()
+
+		budget := ratelimit.Budget{Rate: 0.001, Burst: 2}
+
+		_, err := s.Take(ctx, "read:192.0.2.1", budget)
+		assert.NilError(t, err)
+
+		_, err = s.Take(ctx, "read:192.0.2.2", budget)
+		assert.NilError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		swept, err := s.Sweep(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, swept, int64(2))
+
+		// A swept bucket starts full again.
+		d, err := s.Take(ctx, "read:192.0.2.1", budget)
+		assert.NilError(t, err)
+		assert.Equal(t, d.Remaining, 1)
+	})
+}
+
+func TestRateLimitModel(t *testing.T) {
+	testRateLimitModel(t, func(t *testing.T, idle time.Duration) sweeper {
+		return &RateLimitModel{DB: newTestDB(t, "mysql", "SNIPPETBOX_TEST_MYSQL_DSN"), Idle: idle}
+	})
+}
+
+func TestPostgresRateLimitModel(t *testing.T) {
+	testRateLimitModel(t, func(t *testing.T, idle time.Duration) sweeper {
+		return &PostgresRateLimitModel{DB: newTestDB(t, "postgres", "SNIPPETBOX_TEST_POSTGRES_DSN"), Idle: idle}
+	})
+}
+
+func TestContended(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"MySQL Deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"MySQL Lock Wait Timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"MySQL Other", &mysql.MySQLError{Number: 1146}, false},
+		{"PostgreSQL Deadlock", &pq.Error{Code: "40P01"}, true},
+		{"PostgreSQL Serialization Failure", fmt.Errorf("taking: %w", &pq.Error{Code: "40001"}), true},
+		{"Connection Refused", errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := contended(tt.err)
+			assert.Equal(t, errors.Is(err, ratelimit.ErrContended), tt.want)
+			assert.Equal(t, errors.Is(err, tt.err), true)
+		})
+	}
+}
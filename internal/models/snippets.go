@@ -1,45 +1,76 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
-	"snippetbox.bimasenaputra/internal/util"
 )
 
 type Snippet struct {
 	ID int
 	Title string
-	Content string
+	Files []*SnippetFile
 	Created time.Time
 	Expires time.Time
+	// Parent is the snippet this one was forked from, or nil.
+	Parent *SnippetRef
+}
+
+// SnippetRef points at another snippet. The snippet it refers to may have
+// expired since, in which case Live is false and only ID is reliable.
+type SnippetRef struct {
+	ID int
+	Title string
+	Live bool
+}
+
+// SnippetFile is one named file of a snippet. Files keep the order they were
+// submitted in and names are unique within a snippet.
+type SnippetFile struct {
+	Name string
+	Language string
+	Content string
+}
+
+// File returns the file of s called name, or nil if there is none.
+func (s *Snippet) File(name string) *SnippetFile {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
 }
 
 type SnippetModelInterface interface {
-	Insert(string, string, int) (int, error)
-	Get(int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
-	GetMaxID() (int, error)
-	GetMinID() (int, error)
-	NextLatestPaging(int) ([]*Snippet, error)
-	PrevLatestPaging(int) ([]*Snippet, error)
-	LatestContainsTitle(string) ([]*Snippet, error)
-	GetMaxIDByTitle(string) (int, error)
-	GetMinIDByTitle(string) (int, error)
-	NextLatestContainsTitle(int, string) ([]*Snippet, error)
-	PrevLatestContainsTitle(int, string) ([]*Snippet, error)  
+	Insert(context.Context, string, []*SnippetFile, []string, int, int) (int, error)
+	Get(context.Context, int) (*Snippet, error)
+	Exists(context.Context, int) (bool, error)
+	Page(context.Context, SnippetFilter) (*SnippetPage, error)
+	Forks(context.Context, int, int) ([]*Snippet, error)
+	Counts(context.Context) (int, int, error)
 }
 
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
+// Insert stores a new snippet with its files and tags in one transaction. A
+// non-zero parentID records the snippet it was forked from.
+func (m *SnippetModel) Insert(ctx context.Context, title string, files []*SnippetFile, tags []string, expires int, parentID int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
 
-	stmt := `INSERT INTO SNIPPETS (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO SNIPPETS (title, created, expires, parent_id)
+	VALUES(?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?)`
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := tx.ExecContext(ctx, stmt, title, expires, nullID(parentID))
 	
 	if err != nil {
 		return 0, err
@@ -51,17 +82,36 @@ func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
 		return 0, err
 	}
 
-	return int(id), nil
+	for i, f := range files {
+		stmt := `INSERT INTO SNIPPET_FILES (snippet_id, position, name, language, content)
+		VALUES(?, ?, ?, ?, ?)`
+
+		_, err = tx.ExecContext(ctx, stmt, id, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = addTags(ctx, tx, int(id), tags)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
 
-	stmt := `SELECT * FROM SNIPPETS 
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT s.id, s.title, s.created, s.expires,
+	s.parent_id, p.title, p.expires > UTC_TIMESTAMP()
+	FROM SNIPPETS s LEFT JOIN SNIPPETS p ON p.id = s.parent_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`
 
 	s := &Snippet{}
+	parent := &nullSnippetRef{}
 
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Title, &s.Created, &s.Expires,
+		&parent.ID, &parent.Title, &parent.Live)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -71,263 +121,152 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
-	return s, nil
-}
-
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-
-	stmt := `SELECT * FROM SNIPPETS 
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-
-	rows, err := m.DB.Query(stmt)
-
+	rows, err := m.DB.QueryContext(ctx, `SELECT name, language, content FROM SNIPPET_FILES
+	WHERE snippet_id = ? ORDER BY position`, id)
 	if err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
-
-	snippets := []*Snippet{}
-
-	for rows.Next() {
-		s := &Snippet{}
-
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-
-		if err != nil {
-			return nil, err
-		}
-
-		snippets = append(snippets, s)
-	}
-
-	if err = rows.Err(); err != nil {
+	s.Files, err = scanSnippetFiles(rows)
+	if err != nil {
 		return nil, err
 	}
 
-	return snippets, nil
-}
-
-func (m *SnippetModel) GetMaxID() (int, error) {
-	stmt := `SELECT MAX(id) FROM SNIPPETS
-	WHERE expires > UTC_TIMESTAMP()`
+	s.Parent = parent.ref()
 
-	var id *int
-
-	err := m.DB.QueryRow(stmt).Scan(&id)
-	if err != nil {
-		return 0, err
-	}
-
-	return *id, nil
+	return s, nil
 }
 
-func (m *SnippetModel) GetMinID() (int, error) {
-	stmt := `SELECT MIN(id) FROM SNIPPETS
-	WHERE expires > UTC_TIMESTAMP()`
+func (m *SnippetModel) Page(ctx context.Context, filter SnippetFilter) (*SnippetPage, error) {
+	where := "expires > UTC_TIMESTAMP()"
+	args := []any{}
 
-	var id *int
+	if filter.Title != "" {
+		where += " AND MATCH(title) AGAINST(?)"
+		args = append(args, filter.Title)
+	}
 
-	err := m.DB.QueryRow(stmt).Scan(&id)
-	if err != nil {
-		return 0, err
+	if filter.Tag != "" {
+		where += ` AND id IN (SELECT st.snippet_id FROM SNIPPET_TAGS st
+		JOIN TAGS t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, filter.Tag)
 	}
 
-	return *id, nil
-}
+	var total, newer int
 
-func (m *SnippetModel) NextLatestPaging(id int) ([]*Snippet, error) {
-	stmt := `SELECT * FROM SNIPPETS
-	WHERE id < ? AND expires > UTC_TIMESTAMP()
-	ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT COUNT(*), COALESCE(SUM(id > ?), 0) FROM SNIPPETS WHERE ` + where
 
-	result, err := m.DB.Query(stmt, id)
+	err := m.DB.QueryRowContext(ctx, stmt, append([]any{filter.threshold()}, args...)...).Scan(&total, &newer)
 	if err != nil {
 		return nil, err
 	}
 
-	defer result.Close()
-
-	snippets := []*Snippet{}
-
-	for result.Next() {
-		s := &Snippet{}
+	cursor, limit := filter.position(total)
+	order := "DESC"
 
-		err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-		if err != nil {
-			return nil, err
+	if cursor != nil {
+		if cursor.Prev {
+			where += " AND id > ?"
+			order = "ASC"
+		} else {
+			where += " AND id < ?"
 		}
-
-		snippets = append(snippets, s)
-	}
-
-	if err = result.Err(); err != nil {
-		return nil, err
+		args = append(args, cursor.ID)
 	}
 
-	return snippets, nil
-}
+	stmt = fmt.Sprintf(`SELECT id, title, created, expires FROM SNIPPETS
+	WHERE %s ORDER BY id %s LIMIT ?`, where, order)
 
-func (m *SnippetModel) PrevLatestPaging(id int) ([]*Snippet, error) {
-	stmt := `SELECT * FROM SNIPPETS
-	WHERE id > ? AND expires > UTC_TIMESTAMP()
-	ORDER BY id LIMIT 10`
+	args = append(args, limit+1)
 
-	result, err := m.DB.Query(stmt, id)
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	defer result.Close()
-
-	snippets := []*Snippet{}
-
-	for result.Next() {
-		s := &Snippet{}
-
-		err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-		if err != nil {
-			return nil, err
-		}
-
-		snippets = append(snippets, s)
-	}
-
-	if err = result.Err(); err != nil {
-		return nil, err
-	}
-
-	util.Reverse(snippets)
-
-	return snippets, nil
-}
-
-func (m *SnippetModel) LatestContainsTitle(title string) ([]*Snippet, error) {
-	stmt := `SELECT * FROM SNIPPETS
-	WHERE expires > UTC_TIMESTAMP() AND MATCH(title) AGAINST(?)
-	ORDER BY id DESC LIMIT 10`
-
-	result, err := m.DB.Query(stmt, title)
+	snippets, err := scanSnippets(rows)
 	if err != nil {
 		return nil, err
 	}
 
-	defer result.Close()
-
-	snippets := []*Snippet{}
-
-	for result.Next() {
-		s := &Snippet{}
-
-		err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-		if err != nil {
-			return nil, err
-		}
+	return newSnippetPage(snippets, cursor, limit, filter, total, newer), nil
+}
 
-		snippets = append(snippets, s)
-	}
+// Exists reports whether snippet id is stored, live or expired, and so can
+// be the parent of a fork.
+func (m *SnippetModel) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
 
-	if err = result.Err(); err != nil {
-		return nil, err
-	}
-
-	return snippets, nil
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM SNIPPETS WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
 }
 
-func (m *SnippetModel) GetMaxIDByTitle(title string) (int, error) {
-	stmt := `SELECT MAX(id) FROM SNIPPETS
-	WHERE expires > UTC_TIMESTAMP() AND MATCH(title) AGAINST(?)`
-
-	var id *int
+// Forks returns up to limit live snippets forked from id, newest first.
+func (m *SnippetModel) Forks(ctx context.Context, id int, limit int) ([]*Snippet, error) {
+	stmt := `SELECT id, title, created, expires FROM SNIPPETS
+	WHERE parent_id = ? AND expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT ?`
 
-	err := m.DB.QueryRow(stmt, title).Scan(&id)
+	rows, err := m.DB.QueryContext(ctx, stmt, id, limit)
 	if err != nil {
-		return 0, err
-	} else if id == nil {
-		return 0, ErrNoRecord
+		return nil, err
 	}
 
-	return *id, err
+	return scanSnippets(rows)
 }
 
-func (m *SnippetModel) GetMinIDByTitle(title string) (int, error) {
-	stmt := `SELECT MIN(id) FROM SNIPPETS
-	WHERE expires > UTC_TIMESTAMP() AND MATCH(title) AGAINST(?)`
+// Counts returns how many stored snippets are live and how many expired.
+func (m *SnippetModel) Counts(ctx context.Context) (int, int, error) {
+	stmt := `SELECT COALESCE(SUM(expires > UTC_TIMESTAMP()), 0),
+	COALESCE(SUM(expires <= UTC_TIMESTAMP()), 0) FROM SNIPPETS`
 
-	var id *int
+	var live, expired int
 
-	err := m.DB.QueryRow(stmt, title).Scan(&id)
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&live, &expired)
 	if err != nil {
-		return 0, err
-	} else if id == nil {
-		return 0, ErrNoRecord
+		return 0, 0, err
 	}
 
-	return *id, err
+	return live, expired, nil
 }
 
-func (m *SnippetModel) NextLatestContainsTitle(id int, title string) ([]*Snippet, error) {
-	stmt := `SELECT * FROM SNIPPETS
-	WHERE id < ? AND expires > UTC_TIMESTAMP() AND MATCH(title) AGAINST(?)
-	ORDER BY id DESC LIMIT 10`
-
-	result, err := m.DB.Query(stmt, id, title)
-	if err != nil {
-		return nil, err
-	}
-
-	defer result.Close()
-
-	snippets := []*Snippet{}
-
-	for result.Next() {
-		s := &Snippet{}
-
-		err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-		if err != nil {
-			return nil, err
-		}
-
-		snippets = append(snippets, s)
-	}
+// nullSnippetRef scans the columns of a left-joined parent snippet.
+type nullSnippetRef struct {
+	ID sql.NullInt64
+	Title sql.NullString
+	Live sql.NullBool
+}
 
-	if err = result.Err(); err != nil {
-		return nil, err
+func (r *nullSnippetRef) ref() *SnippetRef {
+	if !r.ID.Valid {
+		return nil
 	}
-
-	return snippets, nil
+	return &SnippetRef{ID: int(r.ID.Int64), Title: r.Title.String, Live: r.Live.Bool}
 }
 
-func (m *SnippetModel) PrevLatestContainsTitle(id int, title string) ([]*Snippet, error) {
-	stmt := `SELECT * FROM SNIPPETS
-	WHERE id > ? AND expires > UTC_TIMESTAMP() AND MATCH(title) AGAINST(?)
-	ORDER BY id LIMIT 10`
-
-	result, err := m.DB.Query(stmt, id, title)
-	if err != nil {
-		return nil, err
-	}
+// nullID maps the zero ID to NULL.
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
 
-	defer result.Close()
+func scanSnippetFiles(rows *sql.Rows) ([]*SnippetFile, error) {
+	defer rows.Close()
 
-	snippets := []*Snippet{}
+	files := []*SnippetFile{}
 
-	for result.Next() {
-		s := &Snippet{}
+	for rows.Next() {
+		f := &SnippetFile{}
 
-		err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&f.Name, &f.Language, &f.Content)
 		if err != nil {
 			return nil, err
 		}
 
-		snippets = append(snippets, s)
+		files = append(files, f)
 	}
 
-	if err = result.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	util.Reverse(snippets)
-
-	return snippets, nil
-}
\ No newline at end of file
+	return files, nil
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PostgresSnippetModel is the PostgreSQL implementation of SnippetModelInterface.
+// Timestamps are stored in UTC and title search uses the title_tsv column.
+type PostgresSnippetModel struct {
+	DB *sql.DB
+}
+
+func (m *PostgresSnippetModel) Insert(ctx context.Context, title string, files []*SnippetFile, tags []string, expires int, parentID int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, created, expires, parent_id)
+	VALUES($1, NOW() AT TIME ZONE 'UTC', (NOW() AT TIME ZONE 'UTC') + $2 * INTERVAL '1 day', $3)
+	RETURNING id`
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, stmt, title, expires, nullID(parentID)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, f := range files {
+		stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+		VALUES($1, $2, $3, $4, $5)`
+
+		_, err = tx.ExecContext(ctx, stmt, id, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = addTagsPostgres(ctx, tx, id, tags)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (m *PostgresSnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	stmt := `SELECT s.id, s.title, s.created, s.expires,
+	s.parent_id, p.title, p.expires > NOW() AT TIME ZONE 'UTC'
+	FROM snippets s LEFT JOIN snippets p ON p.id = s.parent_id
+	WHERE s.expires > NOW() AT TIME ZONE 'UTC' AND s.id = $1`
+
+	s := &Snippet{}
+	parent := &nullSnippetRef{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Title, &s.Created, &s.Expires,
+		&parent.ID, &parent.Title, &parent.Live)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT name, language, content FROM snippet_files
+	WHERE snippet_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Files, err = scanSnippetFiles(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Parent = parent.ref()
+
+	return s, nil
+}
+
+func (m *PostgresSnippetModel) Page(ctx context.Context, filter SnippetFilter) (*SnippetPage, error) {
+	conditions := []string{"expires > NOW() AT TIME ZONE 'UTC'"}
+	args := []any{}
+
+	if filter.Title != "" {
+		args = append(args, filter.Title)
+		conditions = append(conditions, fmt.Sprintf("title_tsv @@ plainto_tsquery('simple', $%d)", len(args)))
+	}
+
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf(`id IN (SELECT st.snippet_id FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE t.name = $%d)`, len(args)))
+	}
+
+	var total, newer int
+
+	stmt := fmt.Sprintf(`SELECT COUNT(*), COUNT(*) FILTER (WHERE id > $%d) FROM snippets WHERE %s`,
+		len(args)+1, strings.Join(conditions, " AND "))
+
+	err := m.DB.QueryRowContext(ctx, stmt, append(args, filter.threshold())...).Scan(&total, &newer)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, limit := filter.position(total)
+	order := "DESC"
+
+	if cursor != nil {
+		args = append(args, cursor.ID)
+		if cursor.Prev {
+			conditions = append(conditions, fmt.Sprintf("id > $%d", len(args)))
+			order = "ASC"
+		} else {
+			conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+		}
+	}
+
+	args = append(args, limit+1)
+
+	stmt = fmt.Sprintf(`SELECT id, title, created, expires FROM snippets
+	WHERE %s ORDER BY id %s LIMIT $%d`, strings.Join(conditions, " AND "), order, len(args))
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnippetPage(snippets, cursor, limit, filter, total, newer), nil
+}
+
+func (m *PostgresSnippetModel) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM snippets WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (m *PostgresSnippetModel) Forks(ctx context.Context, id int, limit int) ([]*Snippet, error) {
+	stmt := `SELECT id, title, created, expires FROM snippets
+	WHERE parent_id = $1 AND expires > NOW() AT TIME ZONE 'UTC' ORDER BY id DESC LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+func (m *PostgresSnippetModel) Counts(ctx context.Context) (int, int, error) {
+	stmt := `SELECT COUNT(*) FILTER (WHERE expires > NOW() AT TIME ZONE 'UTC'),
+	COUNT(*) FILTER (WHERE expires <= NOW() AT TIME ZONE 'UTC') FROM snippets`
+
+	var live, expired int
+
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&live, &expired)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return live, expired, nil
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+// testSnippetModel is the conformance suite every SnippetModelInterface
+// backend must pass. newModel must return a model backed by an empty store.
+func testSnippetModel(t *testing.T, newModel func(t *testing.T) SnippetModelInterface) {
+	t.Run("Insert and Get", func(t *testing.T) {
+		m := newModel(t)
+
+		files := []*SnippetFile{
+			{Name: "main.go", Language: "go", Content: "package main"},
+			{Name: "go.mod", Language: "plaintext", Content: "module haiku"},
+		}
+
+		id, err := m.Insert(context.Background(), "An old silent pond", files, nil, 7, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, id > 0, true)
+
+		s, err := m.Get(context.Background(), id)
+		assert.NilError(t, err)
+		assert.Equal(t, s.ID, id)
+		assert.Equal(t, s.Title, "An old silent pond")
+		assert.Equal(t, len(s.Files), 2)
+		assert.Equal(t, *s.Files[0], *files[0])
+		assert.Equal(t, *s.Files[1], *files[1])
+		assert.Equal(t, s.File("go.mod").Content, "module haiku")
+
+		lifetime := s.Expires.Sub(s.Created)
+		assert.Equal(t, lifetime > 7*24*time.Hour-time.Minute && lifetime < 7*24*time.Hour+time.Minute, true)
+	})
+
+	t.Run("Get missing", func(t *testing.T) {
+		m := newModel(t)
+
+		_, err := m.Get(context.Background(), 1)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+
+	t.Run("Get expired", func(t *testing.T) {
+		m := newModel(t)
+
+		id, err := m.Insert(context.Background(), "Expired", haikuFiles(), nil, -1, 0)
+		assert.NilError(t, err)
+
+		_, err = m.Get(context.Background(), id)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+
+	t.Run("Forks", func(t *testing.T) {
+		m := newModel(t)
+
+		parent, err := m.Insert(context.Background(), "An old silent pond", haikuFiles(), nil, 7, 0)
+		assert.NilError(t, err)
+
+		s, err := m.Get(context.Background(), parent)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Parent == nil, true)
+
+		fork, err := m.Insert(context.Background(), "A frog jumps in", haikuFiles(), nil, 7, parent)
+		assert.NilError(t, err)
+
+		_, err = m.Insert(context.Background(), "Expired fork", haikuFiles(), nil, -1, parent)
+		assert.NilError(t, err)
+
+		s, err = m.Get(context.Background(), fork)
+		assert.NilError(t, err)
+		assert.Equal(t, *s.Parent, SnippetRef{ID: parent, Title: "An old silent pond", Live: true})
+
+		forks, err := m.Forks(context.Background(), parent, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(forks), 1)
+		assert.Equal(t, forks[0].ID, fork)
+
+		forks, err = m.Forks(context.Background(), fork, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(forks), 0)
+	})
+
+	t.Run("Fork of expired", func(t *testing.T) {
+		m := newModel(t)
+
+		parent, err := m.Insert(context.Background(), "Expired", haikuFiles(), nil, -1, 0)
+		assert.NilError(t, err)
+
+		fork, err := m.Insert(context.Background(), "A frog jumps in", haikuFiles(), nil, 7, parent)
+		assert.NilError(t, err)
+
+		s, err := m.Get(context.Background(), fork)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Parent.ID, parent)
+		assert.Equal(t, s.Parent.Live, false)
+
+		exists, err := m.Exists(context.Background(), parent)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		exists, err = m.Exists(context.Background(), fork+1000)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, false)
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		m := newModel(t)
+
+		insertSnippets(t, m, 3)
+
+		_, err := m.Insert(context.Background(), "Expired", haikuFiles(), nil, -1, 0)
+		assert.NilError(t, err)
+
+		live, expired, err := m.Counts(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, live, 3)
+		assert.Equal(t, expired, 1)
+	})
+
+	t.Run("First page", func(t *testing.T) {
+		m := newModel(t)
+
+		page, err := m.Page(context.Background(), SnippetFilter{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+		assert.Equal(t, page.HasNext, false)
+		assert.Equal(t, page.HasPrev, false)
+
+		ids := insertSnippets(t, m, 12)
+
+		_, err = m.Insert(context.Background(), "Expired", haikuFiles(), nil, -1, 0)
+		assert.NilError(t, err)
+
+		page, err = m.Page(context.Background(), SnippetFilter{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 10)
+		assert.Equal(t, page.Snippets[0].ID, ids[11])
+		assert.Equal(t, page.Snippets[9].ID, ids[2])
+		assert.Equal(t, page.HasNext, true)
+		assert.Equal(t, page.HasPrev, false)
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		m := newModel(t)
+		ids := insertSnippets(t, m, 25)
+
+		next, err := m.Page(context.Background(), SnippetFilter{Cursor: &Cursor{ID: ids[15]}})
+		assert.NilError(t, err)
+		assert.Equal(t, len(next.Snippets), 10)
+		assert.Equal(t, next.Snippets[0].ID, ids[14])
+		assert.Equal(t, next.Snippets[9].ID, ids[5])
+		assert.Equal(t, next.HasNext, true)
+		assert.Equal(t, next.HasPrev, true)
+		assert.Equal(t, next.Number(), 2)
+
+		prev, err := m.Page(context.Background(), SnippetFilter{Cursor: next.PrevCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(prev.Snippets), 10)
+		assert.Equal(t, prev.Snippets[0].ID, ids[24])
+		assert.Equal(t, prev.Snippets[9].ID, ids[15])
+		assert.Equal(t, prev.HasNext, true)
+		assert.Equal(t, prev.HasPrev, false)
+
+		last, err := m.Page(context.Background(), SnippetFilter{Cursor: next.NextCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(last.Snippets), 5)
+		assert.Equal(t, last.Snippets[4].ID, ids[0])
+		assert.Equal(t, last.HasNext, false)
+		assert.Equal(t, last.HasPrev, true)
+
+		assert.Equal(t, last.Total, 25)
+		assert.Equal(t, last.Number(), 3)
+		assert.Equal(t, last.Pages(), 3)
+
+		small, err := m.Page(context.Background(), SnippetFilter{Limit: 5})
+		assert.NilError(t, err)
+		assert.Equal(t, len(small.Snippets), 5)
+		assert.Equal(t, small.HasNext, true)
+		assert.Equal(t, small.Number(), 1)
+		assert.Equal(t, small.Pages(), 5)
+	})
+
+	t.Run("Last page", func(t *testing.T) {
+		m := newModel(t)
+		ids := insertSnippets(t, m, 23)
+
+		last, err := m.Page(context.Background(), SnippetFilter{Last: true})
+		assert.NilError(t, err)
+		assert.Equal(t, len(last.Snippets), 3)
+		assert.Equal(t, last.Snippets[0].ID, ids[2])
+		assert.Equal(t, last.Snippets[2].ID, ids[0])
+		assert.Equal(t, last.HasNext, false)
+		assert.Equal(t, last.HasPrev, true)
+		assert.Equal(t, last.Number(), 3)
+
+		prev, err := m.Page(context.Background(), SnippetFilter{Cursor: last.PrevCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(prev.Snippets), 10)
+		assert.Equal(t, prev.Snippets[0].ID, ids[12])
+		assert.Equal(t, prev.Number(), 2)
+		assert.Equal(t, prev.HasNext, true)
+		assert.Equal(t, prev.HasPrev, true)
+
+		last, err = m.Page(context.Background(), SnippetFilter{Last: true, Limit: 23})
+		assert.NilError(t, err)
+		assert.Equal(t, len(last.Snippets), 23)
+		assert.Equal(t, last.HasPrev, false)
+		assert.Equal(t, last.Number(), 1)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		m := newModel(t)
+		ids := insertSnippets(t, m, 25)
+
+		_, err := m.Insert(context.Background(), "Over the wintry forest", haikuFiles(), nil, 7, 0)
+		assert.NilError(t, err)
+
+		page, err := m.Page(context.Background(), SnippetFilter{Title: "haiku"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 10)
+		assert.Equal(t, page.Snippets[0].ID, ids[24])
+		assert.Equal(t, page.HasNext, true)
+
+		next, err := m.Page(context.Background(), SnippetFilter{Title: "haiku", Cursor: page.NextCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(next.Snippets), 10)
+		assert.Equal(t, next.Snippets[0].ID, ids[14])
+
+		prev, err := m.Page(context.Background(), SnippetFilter{Title: "haiku", Cursor: next.PrevCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(prev.Snippets), 10)
+		assert.Equal(t, prev.Snippets[0].ID, ids[24])
+		assert.Equal(t, prev.HasPrev, false)
+
+		assert.Equal(t, prev.Total, 25)
+
+		page, err = m.Page(context.Background(), SnippetFilter{Title: "wintry"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 1)
+		assert.Equal(t, page.HasNext, false)
+
+		page, err = m.Page(context.Background(), SnippetFilter{Title: "nothing"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+	})
+}
+
+func insertSnippets(t *testing.T, m SnippetModelInterface, n int) []int {
+	t.Helper()
+
+	ids := make([]int, n)
+
+	for i := range ids {
+		id, err := m.Insert(context.Background(), fmt.Sprintf("Haiku number %d", i), haikuFiles(), nil, 7, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+
+	return ids
+}
+
+func TestSnippetModel(t *testing.T) {
+	testSnippetModel(t, func(t *testing.T) SnippetModelInterface {
+		return &SnippetModel{DB: newTestDB(t, "mysql", "SNIPPETBOX_TEST_MYSQL_DSN")}
+	})
+}
+
+func TestPostgresSnippetModel(t *testing.T) {
+	testSnippetModel(t, func(t *testing.T) SnippetModelInterface {
+		return &PostgresSnippetModel{DB: newTestDB(t, "postgres", "SNIPPETBOX_TEST_POSTGRES_DSN")}
+	})
+}
+
+func haikuFiles() []*SnippetFile {
+	return []*SnippetFile{{Name: "haiku.txt", Language: "plaintext", Content: "An old silent pond..."}}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+type TagModelInterface interface {
+	ForSnippet(context.Context, int) ([]string, error)
+	Cloud(context.Context, int) ([]*TagCount, error)
+}
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+// addTags tags a snippet within tx, creating any tag that doesn't exist yet.
+func addTags(ctx context.Context, tx *sql.Tx, snippetID int, names []string) error {
+	for _, name := range names {
+		_, err := tx.ExecContext(ctx, `INSERT IGNORE INTO TAGS (name) VALUES(?)`, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO SNIPPET_TAGS (snippet_id, tag_id)
+		SELECT ?, id FROM TAGS WHERE name = ?`, snippetID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *TagModel) ForSnippet(ctx context.Context, snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM TAGS t
+	JOIN SNIPPET_TAGS st ON st.tag_id = t.id
+	WHERE st.snippet_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTagNames(rows)
+}
+
+// Cloud returns the limit most used tags among live snippets.
+func (m *TagModel) Cloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) AS uses FROM TAGS t
+	JOIN SNIPPET_TAGS st ON st.tag_id = t.id
+	JOIN SNIPPETS s ON s.id = st.snippet_id
+	WHERE s.expires > UTC_TIMESTAMP()
+	GROUP BY t.id, t.name ORDER BY uses DESC, t.name LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTagCounts(rows)
+}
+
+func scanTagNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	names := []string{}
+
+	for rows.Next() {
+		var name string
+
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func scanTagCounts(rows *sql.Rows) ([]*TagCount, error) {
+	defer rows.Close()
+
+	counts := []*TagCount{}
+
+	for rows.Next() {
+		c := &TagCount{}
+
+		err := rows.Scan(&c.Name, &c.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PostgresTagModel is the PostgreSQL implementation of TagModelInterface.
+type PostgresTagModel struct {
+	DB *sql.DB
+}
+
+func addTagsPostgres(ctx context.Context, tx *sql.Tx, snippetID int, names []string) error {
+	for _, name := range names {
+		_, err := tx.ExecContext(ctx, `INSERT INTO tags (name) VALUES($1) ON CONFLICT (name) DO NOTHING`, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO snippet_tags (snippet_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = $2`, snippetID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *PostgresTagModel) ForSnippet(ctx context.Context, snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	WHERE st.snippet_id = $1 ORDER BY t.name`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTagNames(rows)
+}
+
+func (m *PostgresTagModel) Cloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) AS uses FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > NOW() AT TIME ZONE 'UTC'
+	GROUP BY t.id, t.name ORDER BY uses DESC, t.name LIMIT $1`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTagCounts(rows)
+}
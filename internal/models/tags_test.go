@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+// testTagModel is the conformance suite every TagModelInterface backend must
+// pass. newModels must return models sharing one empty store.
+func testTagModel(t *testing.T, newModels func(t *testing.T) (SnippetModelInterface, TagModelInterface)) {
+	t.Run("Insert with tags", func(t *testing.T) {
+		snippets, tags := newModels(t)
+
+		id, err := snippets.Insert(context.Background(), "An old silent pond", haikuFiles(), []string{"sql", "bash"}, 7, 0)
+		assert.NilError(t, err)
+
+		// Tags are shared between snippets.
+		other := insertTagged(t, snippets, "sql")
+
+		names, err := tags.ForSnippet(context.Background(), id)
+		assert.NilError(t, err)
+		assert.Equal(t, len(names), 2)
+		assert.Equal(t, names[0], "bash")
+		assert.Equal(t, names[1], "sql")
+
+		names, err = tags.ForSnippet(context.Background(), other)
+		assert.NilError(t, err)
+		assert.Equal(t, len(names), 1)
+		assert.Equal(t, names[0], "sql")
+
+		// A tag the database refuses leaves no snippet behind without tags.
+		_, err = snippets.Insert(context.Background(), "A frog jumps in", haikuFiles(), []string{"sql", strings.Repeat("x", 100)}, 7, 0)
+		assert.Equal(t, err != nil, true)
+
+		live, _, err := snippets.Counts(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, live, 1)
+	})
+
+	t.Run("Page by tag", func(t *testing.T) {
+		snippets, _ := newModels(t)
+
+		ids := []int{}
+		for range 12 {
+			ids = append(ids, insertTagged(t, snippets, "sql"))
+		}
+		insertSnippets(t, snippets, 3)
+
+		page, err := snippets.Page(context.Background(), SnippetFilter{Tag: "sql"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 10)
+		assert.Equal(t, page.Snippets[0].ID, ids[11])
+		assert.Equal(t, page.Total, 12)
+		assert.Equal(t, page.HasNext, true)
+
+		next, err := snippets.Page(context.Background(), SnippetFilter{Tag: "sql", Cursor: page.NextCursor()})
+		assert.NilError(t, err)
+		assert.Equal(t, len(next.Snippets), 2)
+		assert.Equal(t, next.HasNext, false)
+
+		page, err = snippets.Page(context.Background(), SnippetFilter{Tag: "bash"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+	})
+
+	t.Run("Cloud", func(t *testing.T) {
+		snippets, tags := newModels(t)
+
+		insertTagged(t, snippets, "sql", "bash")
+		insertTagged(t, snippets, "sql")
+		insertTagged(t, snippets, "k8s")
+
+		_, err := snippets.Insert(context.Background(), "Expired", haikuFiles(), []string{"k8s", "go"}, -1, 0)
+		assert.NilError(t, err)
+
+		cloud, err := tags.Cloud(context.Background(), 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cloud), 3)
+		assert.Equal(t, *cloud[0], TagCount{Name: "sql", Count: 2})
+		assert.Equal(t, *cloud[1], TagCount{Name: "bash", Count: 1})
+		assert.Equal(t, *cloud[2], TagCount{Name: "k8s", Count: 1})
+
+		cloud, err = tags.Cloud(context.Background(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cloud), 1)
+	})
+}
+
+// insertTagged inserts a live snippet with the given tags and returns its ID.
+func insertTagged(t *testing.T, m SnippetModelInterface, tags ...string) int {
+	t.Helper()
+
+	id, err := m.Insert(context.Background(), "Tagged haiku", haikuFiles(), tags, 7, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func TestTagModel(t *testing.T) {
+	testTagModel(t, func(t *testing.T) (SnippetModelInterface, TagModelInterface) {
+		db := newTestDB(t, "mysql", "SNIPPETBOX_TEST_MYSQL_DSN")
+		return &SnippetModel{DB: db}, &TagModel{DB: db}
+	})
+}
+
+func TestPostgresTagModel(t *testing.T) {
+	testTagModel(t, func(t *testing.T) (SnippetModelInterface, TagModelInterface) {
+		db := newTestDB(t, "postgres", "SNIPPETBOX_TEST_POSTGRES_DSN")
+		return &PostgresSnippetModel{DB: db}, &PostgresTagModel{DB: db}
+	})
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"snippetbox.bimasenaputra/internal/migrations"
+)
+
+// newTestDB opens a connection to the test database for the given backend and
+// applies every migration, rolling them back again when the test finishes. The
+// DSN is read from the environment so the suite is skipped on machines without
+// a database available.
+func newTestDB(t *testing.T, driver, dsnEnv string) *sql.DB {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("models: %s not set", dsnEnv)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrations.New(db, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for {
+			_, err := migrator.Down(context.Background())
+			if errors.Is(err, migrations.ErrNoChange) {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		db.Close()
+	})
+
+	return db
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer("snippetbox.bimasenaputra/internal/models")
+}
+
+// startSpan starts a client span for a model method on the given database
+// system. end must be called with the method's error.
+func startSpan(ctx context.Context, name, system string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	attrs = append(attrs, attribute.String("db.system.name", system))
+
+	ctx, span := tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return ctx, func(err error) {
+		if err != nil && !errors.Is(err, ErrNoRecord) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// TraceSnippets wraps m so that every call is recorded as a span.
+func TraceSnippets(m SnippetModelInterface, system string) SnippetModelInterface {
+	return &tracedSnippetModel{next: m, system: system}
+}
+
+type tracedSnippetModel struct {
+	next   SnippetModelInterface
+	system string
+}
+
+func (m *tracedSnippetModel) Insert(ctx context.Context, title string, files []*SnippetFile, tags []string, expires int, parentID int) (int, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Insert", m.system, attribute.Int("snippet.files", len(files)), attribute.Int("snippet.tags", len(tags)))
+	id, err := m.next.Insert(ctx, title, files, tags, expires, parentID)
+	end(err)
+	return id, err
+}
+
+func (m *tracedSnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Get", m.system, attribute.Int("snippet.id", id))
+	s, err := m.next.Get(ctx, id)
+	end(err)
+	return s, err
+}
+
+func (m *tracedSnippetModel) Page(ctx context.Context, filter SnippetFilter) (*SnippetPage, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Page", m.system,
+		attribute.Bool("snippet.filter.title", filter.Title != ""),
+		attribute.String("snippet.filter.tag", filter.Tag),
+		attribute.Int("snippet.filter.limit", filter.Limit),
+		attribute.Bool("snippet.filter.last", filter.Last))
+	p, err := m.next.Page(ctx, filter)
+	end(err)
+	return p, err
+}
+
+func (m *tracedSnippetModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Exists", m.system, attribute.Int("snippet.id", id))
+	exists, err := m.next.Exists(ctx, id)
+	end(err)
+	return exists, err
+}
+
+func (m *tracedSnippetModel) Forks(ctx context.Context, id int, limit int) ([]*Snippet, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Forks", m.system, attribute.Int("snippet.id", id))
+	forks, err := m.next.Forks(ctx, id, limit)
+	end(err)
+	return forks, err
+}
+
+func (m *tracedSnippetModel) Counts(ctx context.Context) (int, int, error) {
+	ctx, end := startSpan(ctx, "SnippetModel.Counts", m.system)
+	live, expired, err := m.next.Counts(ctx)
+	end(err)
+	return live, expired, err
+}
+
+// TraceTags wraps m so that every call is recorded as a span.
+func TraceTags(m TagModelInterface, system string) TagModelInterface {
+	return &tracedTagModel{next: m, system: system}
+}
+
+type tracedTagModel struct {
+	next   TagModelInterface
+	system string
+}
+
+func (m *tracedTagModel) ForSnippet(ctx context.Context, snippetID int) ([]string, error) {
+	ctx, end := startSpan(ctx, "TagModel.ForSnippet", m.system, attribute.Int("snippet.id", snippetID))
+	names, err := m.next.ForSnippet(ctx, snippetID)
+	end(err)
+	return names, err
+}
+
+func (m *tracedTagModel) Cloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	ctx, end := startSpan(ctx, "TagModel.Cloud", m.system)
+	counts, err := m.next.Cloud(ctx, limit)
+	end(err)
+	return counts, err
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Fallback takes tokens from Shared and falls back to Local when Shared fails
+// or is slower than Timeout. After a failure Shared is left alone for
+// Cooldown, so a store that is down doesn't add Timeout to every request.
+// While falling back, limits only hold per instance, which beats either
+// refusing or letting through every request.
+//
+// Errors wrapping ErrContended only mean that requests raced for the same
+// bucket. The request is answered from Local but Shared stays in use.
+type Fallback struct {
+	Shared   Store
+	Local    Store
+	Timeout  time.Duration
+	Cooldown time.Duration
+	// OnError is called with every error returned by Shared apart from
+	// contention.
+	OnError func(error)
+	// Now returns the current time and defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (f *Fallback) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	if f.sharedDown() {
+		return f.Local.Take(ctx, key, budget)
+	}
+
+	sharedCtx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	d, err := f.Shared.Take(sharedCtx, key, budget)
+	if err == nil {
+		return d, nil
+	}
+
+	if errors.Is(err, ErrContended) {
+		return f.Local.Take(ctx, key, budget)
+	}
+
+	if f.OnError != nil {
+		f.OnError(err)
+	}
+
+	f.mu.Lock()
+	f.downUntil = f.now().Add(f.Cooldown)
+	f.mu.Unlock()
+
+	return f.Local.Take(ctx, key, budget)
+}
+
+func (f *Fallback) sharedDown() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now().Before(f.downUntil)
+}
+
+func (f *Fallback) now() time.Time {
+	if f.Now == nil {
+		return time.Now()
+	}
+	return f.Now()
+}
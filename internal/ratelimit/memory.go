@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in memory, so its limits only hold for one
+// instance. Buckets idle for longer than Idle are dropped; one that idle would
+// have refilled anyway as long as Idle is at least Burst/Rate, so eviction
+// never hands out extra tokens.
+type MemoryStore struct {
+	Idle time.Duration
+	// Now returns the current time; NewMemoryStore sets it to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	swept   time.Time
+}
+
+func NewMemoryStore(idle time.Duration) *MemoryStore {
+	return &MemoryStore{Idle: idle, Now: time.Now, buckets: map[string]*Bucket{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+
+	if now.Sub(s.swept) >= s.Idle {
+		for k, b := range s.buckets {
+			if now.Sub(b.Updated) >= s.Idle {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &Bucket{Tokens: float64(budget.Burst), Updated: now}
+		s.buckets[key] = b
+	}
+
+	return b.Take(budget, now), nil
+}
@@ -0,0 +1,73 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// stores, so that limits can be kept per instance or shared between replicas.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Budget is a token bucket refilled at Rate tokens per second up to Burst.
+type Budget struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied client should wait for the next token
+	// and Reset how long until the bucket is full again.
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// ErrContended is wrapped by the errors of stores that gave up on a bucket
+// because other requests held it, such as after a deadlock. It says nothing
+// about the health of the store.
+var ErrContended = errors.New("ratelimit: bucket contended")
+
+// Store takes tokens from the bucket identified by key, creating a full one
+// the first time key is seen.
+type Store interface {
+	Take(ctx context.Context, key string, budget Budget) (Decision, error)
+}
+
+// Bucket is the state of one token bucket. Stores keep it however they like
+// and use Take to work out the next state.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills b for the time passed since it was last updated and removes
+// one token if there is one.
+func (b *Bucket) Take(budget Budget, now time.Time) Decision {
+	elapsed := now.Sub(b.Updated).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(float64(budget.Burst), b.Tokens+elapsed*budget.Rate)
+		b.Updated = now
+	}
+
+	d := Decision{Limit: budget.Burst}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.Tokens) / budget.Rate)
+	}
+
+	d.Remaining = int(b.Tokens)
+	d.Reset = seconds((float64(budget.Burst) - b.Tokens) / budget.Rate)
+
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
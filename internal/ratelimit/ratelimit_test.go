@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"snippetbox.bimasenaputra/internal/assert"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryStore(t *testing.T) {
+	c := newClock()
+	s := NewMemoryStore(time.Minute)
+	s.Now = c.Now
+
+	budget := Budget{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		d, err := s.Take(context.Background(), "a", budget)
+		assert.NilError(t, err)
+		assert.Equal(t, d.Allowed, true)
+		assert.Equal(t, d.Remaining, 2-i)
+	}
+
+	d, _ := s.Take(context.Background(), "a", budget)
+	assert.Equal(t, d.Allowed, false)
+	assert.Equal(t, d.RetryAfter, 500*time.Millisecond)
+	assert.Equal(t, d.Reset, 1500*time.Millisecond)
+
+	d, _ = s.Take(context.Background(), "b", budget)
+	assert.Equal(t, d.Allowed, true)
+
+	c.advance(500 * time.Millisecond)
+	d, _ = s.Take(context.Background(), "a", budget)
+	assert.Equal(t, d.Allowed, true)
+	assert.Equal(t, d.Remaining, 0)
+
+	// Refilling stops at the burst size.
+	c.advance(time.Hour)
+	d, _ = s.Take(context.Background(), "a", budget)
+	assert.Equal(t, d.Allowed, true)
+	assert.Equal(t, d.Remaining, 2)
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	c := newClock()
+	s := NewMemoryStore(time.Minute)
+	s.Now = c.Now
+
+	budget := Budget{Rate: 1, Burst: 1}
+
+	s.Take(context.Background(), "a", budget)
+	c.advance(30 * time.Second)
+	s.Take(context.Background(), "b", budget)
+	assert.Equal(t, len(s.buckets), 2)
+
+	c.advance(40 * time.Second)
+	s.Take(context.Background(), "c", budget)
+	assert.Equal(t, len(s.buckets), 2)
+
+	_, ok := s.buckets["a"]
+	assert.Equal(t, ok, false)
+}
+
+// failingStore stands in for a shared store that is down.
+type failingStore struct {
+	calls int
+}
+
+func (s *failingStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	s.calls++
+	return Decision{}, errors.New("connection refused")
+}
+
+// contendedStore stands in for a shared store that lost a race for a bucket.
+type contendedStore struct {
+	calls int
+}
+
+func (s *contendedStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	s.calls++
+	return Decision{}, fmt.Errorf("%w: deadlock found", ErrContended)
+}
+
+// slowStore stands in for a shared store that doesn't answer in time.
+type slowStore struct{}
+
+func (s *slowStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	<-ctx.Done()
+	return Decision{}, ctx.Err()
+}
+
+func TestFallback(t *testing.T) {
+	c := newClock()
+	shared := NewMemoryStore(time.Minute)
+	shared.Now = c.Now
+	failing := &failingStore{}
+	errs := 0
+
+	f := &Fallback{
+		Shared:   shared,
+		Local:    NewMemoryStore(time.Minute),
+		Timeout:  time.Second,
+		Cooldown: time.Minute,
+		OnError:  func(error) { errs++ },
+		Now:      c.Now,
+	}
+
+	budget := Budget{Rate: 1, Burst: 1}
+
+	d, err := f.Take(context.Background(), "a", budget)
+	assert.NilError(t, err)
+	assert.Equal(t, d.Allowed, true)
+
+	d, _ = f.Take(context.Background(), "a", budget)
+	assert.Equal(t, d.Allowed, false)
+
+	// Once the shared store fails, limits come from the local one, which has
+	// its own buckets, and the shared store is left alone for a while.
+	f.Shared = failing
+
+	d, err = f.Take(context.Background(), "a", budget)
+	assert.NilError(t, err)
+	assert.Equal(t, d.Allowed, true)
+	assert.Equal(t, errs, 1)
+
+	d, _ = f.Take(context.Background(), "a", budget)
+	assert.Equal(t, d.Allowed, false)
+	assert.Equal(t, failing.calls, 1)
+
+	c.advance(time.Minute)
+	f.Take(context.Background(), "a", budget)
+	assert.Equal(t, failing.calls, 2)
+	assert.Equal(t, errs, 2)
+}
+
+func TestFallbackContended(t *testing.T) {
+	contended := &contendedStore{}
+	errs := 0
+
+	f := &Fallback{
+		Shared:   contended,
+		Local:    NewMemoryStore(time.Minute),
+		Timeout:  time.Second,
+		Cooldown: time.Minute,
+		OnError:  func(error) { errs++ },
+	}
+
+	// Contention isn't an outage: each request still tries the shared store.
+	for i := 0; i < 3; i++ {
+		d, err := f.Take(context.Background(), "a", Budget{Rate: 1, Burst: 5})
+		assert.NilError(t, err)
+		assert.Equal(t, d.Allowed, true)
+	}
+
+	assert.Equal(t, contended.calls, 3)
+	assert.Equal(t, errs, 0)
+}
+
+func TestFallbackTimeout(t *testing.T) {
+	f := &Fallback{
+		Shared:   &slowStore{},
+		Local:    NewMemoryStore(time.Minute),
+		Timeout:  time.Millisecond,
+		Cooldown: time.Minute,
+	}
+
+	d, err := f.Take(context.Background(), "a", Budget{Rate: 1, Burst: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, d.Allowed, true)
+}
@@ -0,0 +1,117 @@
+package twofactor
+
+import (
+	"sync"
+	"time"
+)
+
+// Lockout locks a user out for Duration after MaxFailures failed attempts in
+// a row, none of them more than Duration after the one before. It is kept in
+// memory, so it only holds for one instance.
+//
+// The zero value is ready to use but never locks anyone out; set MaxFailures
+// and Duration, or use NewLockout.
+type Lockout struct {
+	MaxFailures int
+	Duration    time.Duration
+	// Now returns the current time; nil means time.Now.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	users map[string]*lockoutEntry
+	swept time.Time
+}
+
+type lockoutEntry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+func NewLockout(maxFailures int, duration time.Duration) *Lockout {
+	return &Lockout{MaxFailures: maxFailures, Duration: duration, Now: time.Now, users: map[string]*lockoutEntry{}}
+}
+
+// Locked reports whether user is locked out and for how much longer.
+func (l *Lockout) Locked(user string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.users[user]
+	if !ok {
+		return 0, false
+	}
+
+	remaining := e.until.Sub(l.now())
+	return max(remaining, 0), remaining > 0
+}
+
+// Fail records a failed attempt by user and reports whether it locked them
+// out.
+func (l *Lockout) Fail(user string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.MaxFailures <= 0 || l.Duration <= 0 {
+		return false
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	if l.users == nil {
+		l.users = map[string]*lockoutEntry{}
+	}
+
+	e, ok := l.users[user]
+	if !ok {
+		e = &lockoutEntry{}
+		l.users[user] = e
+	}
+
+	if now.Sub(e.last) >= l.Duration {
+		e.failures = 0
+	}
+
+	e.failures++
+	e.last = now
+
+	if e.failures >= l.MaxFailures {
+		e.failures = 0
+		e.until = now.Add(l.Duration)
+		return true
+	}
+
+	return false
+}
+
+// Succeed forgets the failures of user after they got in.
+func (l *Lockout) Succeed(user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.users, user)
+}
+
+func (l *Lockout) now() time.Time {
+	if l.Now == nil {
+		return time.Now()
+	}
+	return l.Now()
+}
+
+// sweep drops users whose failures no longer count and who aren't locked
+// out, at most once per Duration.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.Duration {
+		return
+	}
+
+	for user, e := range l.users {
+		if now.Sub(e.last) >= l.Duration && !now.Before(e.until) {
+			delete(l.users, user)
+		}
+	}
+
+	l.swept = now
+}
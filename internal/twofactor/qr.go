@@ -0,0 +1,79 @@
+package twofactor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"rsc.io/qr"
+)
+
+// quietZone is the blank border, in modules, scanners need around a code.
+const quietZone = 4
+
+// qrImage draws a QR code with its quiet zone, scale pixels per module.
+type qrImage struct {
+	code  *qr.Code
+	scale int
+}
+
+func (m qrImage) ColorModel() color.Model { return color.GrayModel }
+
+func (m qrImage) Bounds() image.Rectangle {
+	size := (m.code.Size + 2*quietZone) * m.scale
+	return image.Rect(0, 0, size, size)
+}
+
+func (m qrImage) At(x, y int) color.Color {
+	if m.code.Black(x/m.scale-quietZone, y/m.scale-quietZone) {
+		return color.Gray{Y: 0x00}
+	}
+	return color.Gray{Y: 0xff}
+}
+
+// QRCodePNG renders text, usually the URL from URL, as a PNG QR code with
+// scale pixels per module.
+func QRCodePNG(text string, scale int) ([]byte, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = png.Encode(&buf, qrImage{code: code, scale: max(scale, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// QRCodeSVG renders text as an SVG QR code, which scales to any size.
+func QRCodeSVG(text string) ([]byte, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	size := code.Size + 2*quietZone
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
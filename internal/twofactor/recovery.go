@@ -0,0 +1,80 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodes is how many recovery codes a user is given at a time.
+const RecoveryCodes = 10
+
+// recoveryAlphabet is Crockford's base32, which leaves out letters easily
+// mistaken for digits. Each code has 16 characters of 5 bits, enough
+// randomness for a plain SHA-256 hash to stand up to guessing.
+const (
+	recoveryAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+	recoveryLength   = 16
+	recoveryGroup    = 4
+)
+
+// NewRecoveryCodes returns n codes to show the user once, formatted as
+// xxxx-xxxx-xxxx-xxxx, and the hashes to store in their place.
+func NewRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+
+	for i := range codes {
+		b := make([]byte, recoveryLength)
+
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var code strings.Builder
+		for j, c := range b {
+			if j > 0 && j%recoveryGroup == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryAlphabet[c%32])
+		}
+
+		codes[i] = code.String()
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash stored for code. Case, spaces and dashes
+// don't matter, so codes typed back in any form match.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchRecoveryCode returns the index of the hash code matches. The caller
+// deletes that hash, so each code works once.
+func MatchRecoveryCode(code string, hashes []string) (int, error) {
+	hash := []byte(HashRecoveryCode(code))
+	match := -1
+
+	// Every hash is compared, so the time taken doesn't tell which matched.
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare(hash, []byte(h)) == 1 {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		return -1, ErrInvalidCode
+	}
+
+	return match, nil
+}
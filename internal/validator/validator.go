@@ -1,10 +1,16 @@
 package validator
 
 import (
+	"regexp"
 	"strings"
 	"unicode/utf8"
 )
 
+var (
+	TagRX = regexp.MustCompile("^[a-z0-9][a-z0-9+#._-]*$")
+	FileNameRX = regexp.MustCompile("^[A-Za-z0-9_][A-Za-z0-9._-]*$")
+)
+
 type Validator struct {
 	FieldErrors map[string]string
 }
@@ -44,4 +50,8 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 		}
 	}
 	return false
+}
+
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
 }
\ No newline at end of file
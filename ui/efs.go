@@ -0,0 +1,10 @@
+// Package ui holds the templates and static files, embedded so the binary
+// can be deployed on its own.
+package ui
+
+import (
+	"embed"
+)
+
+//go:embed "html" "static"
+var Files embed.FS
@@ -1 +1,10 @@
-htmx.config.includeIndicatorStyles = false;
\ No newline at end of file
+htmx.config.includeIndicatorStyles = false;
+
+// Swap error responses into the page like any other, since the server sends
+// an error message in place of the content that was asked for.
+document.addEventListener("htmx:beforeSwap", function (e) {
+    if (e.detail.xhr.status >= 400) {
+        e.detail.shouldSwap = true;
+        e.detail.isError = false;
+    }
+});
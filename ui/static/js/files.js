@@ -0,0 +1,37 @@
+// Adds and removes file rows on the create form without a round trip. The
+// buttons still submit the form when JavaScript is unavailable.
+function initFiles() {
+	var container = document.getElementById("snippet-files");
+	if (!container) {
+		return;
+	}
+
+	var addButton = document.querySelector("button.add-file");
+
+	addButton.addEventListener("click", (event) => {
+		event.preventDefault();
+
+		var rows = container.querySelectorAll("fieldset.snippet-file");
+		var row = rows[rows.length - 1].cloneNode(true);
+
+		row.querySelectorAll("label.error").forEach((label) => label.remove());
+		row.querySelectorAll("input, textarea").forEach((input) => input.value = "");
+		row.querySelector("select").selectedIndex = 0;
+
+		container.appendChild(row);
+	});
+
+	container.addEventListener("click", (event) => {
+		if (!event.target.matches("button.remove-file")) {
+			return;
+		}
+
+		event.preventDefault();
+
+		if (container.querySelectorAll("fieldset.snippet-file").length > 1) {
+			event.target.closest("fieldset.snippet-file").remove();
+		}
+	});
+}
+
+window.addEventListener("load", initFiles);